@@ -2,13 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	. "github.com/dave/jennifer/jen"
 	"github.com/iancoleman/strcase"
-	"io/ioutil"
-	"net/http"
 	"reflect"
-	"time"
+	"strings"
 )
 
 type Action struct {
@@ -24,18 +23,34 @@ type Action struct {
 	} `json:"changelog"`
 	Since           string `json:"since"`
 	DeprecatedSince string `json:"deprecatedSince"`
+	// Versions lists the merged versions that have this action, see mergeSpecs.
+	Versions []string `json:"-"`
 }
 
 type Param struct {
-	Key             string `json:"key"`
-	Description     string `json:"description"`
-	Internal        bool   `json:"internal"`
-	Required        bool   `json:"required"`
-	Since           string `json:"since"`
-	DeprecatedSince string `json:"deprecatedSince"`
+	Key                string   `json:"key"`
+	Description        string   `json:"description"`
+	Internal           bool     `json:"internal"`
+	Required           bool     `json:"required"`
+	Since              string   `json:"since"`
+	DeprecatedSince    string   `json:"deprecatedSince"`
+	DeprecatedKey      string   `json:"deprecatedKey"`
+	DeprecatedKeySince string   `json:"deprecatedKeySince"`
+	PossibleValues     []string `json:"possibleValues"`
+	DefaultValue       string   `json:"defaultValue"`
+	ExampleValue       string   `json:"exampleValue"`
+	// The constraints are nil when the spec does not give them
+	MaxValuesAllowed *int `json:"maxValuesAllowed"`
+	MinimumLength    *int `json:"minimumLength"`
+	MaximumLength    *int `json:"maximumLength"`
+	MinimumValue     *int `json:"minimumValue"`
+	MaximumValue     *int `json:"maximumValue"`
+	// Versions lists the merged versions that have this param, see mergeSpecs.
+	Versions []string `json:"-"`
 }
 
-func (p *Param) render(post bool) *Statement {
+// render renders the request field of the param, enum is the name of its enum type if any
+func (p *Param) render(post bool, enum string) *Statement {
 	var tag string
 	if post {
 		tag = "form"
@@ -47,6 +62,10 @@ func (p *Param) render(post bool) *Statement {
 	if !p.Required {
 		key = fmt.Sprintf("%s,omitempty", key)
 	}
+	// Lists are sent as a single comma-separated value, see the Call method of the client
+	if p.isList() {
+		key = fmt.Sprintf("%s,comma", key)
+	}
 
 	var comment string
 	if p.Since != "" {
@@ -55,10 +74,51 @@ func (p *Param) render(post bool) *Statement {
 	if p.DeprecatedSince != "" {
 		comment += fmt.Sprintf("Deprecated since %s;", p.DeprecatedSince)
 	}
+	if compat := compatibility(p.Versions); compat != "" {
+		comment += fmt.Sprintf("%s;", compat)
+	}
+	if p.DeprecatedKey != "" {
+		comment += fmt.Sprintf("Deprecated key %s", p.DeprecatedKey)
+		if p.DeprecatedKeySince != "" {
+			comment += fmt.Sprintf(" since %s", p.DeprecatedKeySince)
+		}
+		comment += ";"
+	}
+	comment += p.constraints()
 	if p.Description != "" {
 		comment += p.Description
 	}
-	return renderId(p.Key).String().Tag(map[string]string{tag: key}).Comment(comment)
+	return renderId(p.Key).Add(p.goType(enum)).Tag(map[string]string{tag: key}).Comment(comment)
+}
+
+// constraints describes the values the param accepts, e.g. "Possible values: TRK, VW, APP;Default: TRK;"
+func (p *Param) constraints() string {
+	var comment string
+	if len(p.PossibleValues) > 0 {
+		comment += fmt.Sprintf("Possible values: %s;", strings.Join(p.PossibleValues, ", "))
+	}
+	if p.DefaultValue != "" {
+		comment += fmt.Sprintf("Default: %s;", p.DefaultValue)
+	}
+	if p.ExampleValue != "" {
+		comment += fmt.Sprintf("Example: %s;", p.ExampleValue)
+	}
+	if p.MaxValuesAllowed != nil {
+		comment += fmt.Sprintf("Maximum %d values;", *p.MaxValuesAllowed)
+	}
+	if p.MinimumLength != nil {
+		comment += fmt.Sprintf("Minimum length %d;", *p.MinimumLength)
+	}
+	if p.MaximumLength != nil {
+		comment += fmt.Sprintf("Maximum length %d;", *p.MaximumLength)
+	}
+	if p.MinimumValue != nil {
+		comment += fmt.Sprintf("Minimum value %d;", *p.MinimumValue)
+	}
+	if p.MaximumValue != nil {
+		comment += fmt.Sprintf("Maximum value %d;", *p.MaximumValue)
+	}
+	return comment
 }
 
 type ResponseExampleRequest struct {
@@ -126,14 +186,19 @@ func NewResponseFieldsGenerator(parser *FieldParser) *ResponseFieldsGenerator {
 }
 
 func (g *ResponseFieldsGenerator) generate(responseTypeName string, example interface{}) (Field, error) {
+	var response Field
 	if reflect.TypeOf(example) == reflect.TypeOf([]interface{}{}) {
-		return g.parser.NewSliceField(responseTypeName, example.([]interface{})), nil
+		response = g.parser.NewSliceField(nil, responseTypeName, example.([]interface{}))
 	} else {
 		if _, ok := example.(map[string]interface{})["format"]; ok {
 			return &StringField{name: responseTypeName}, nil
 		}
-		return g.parser.NewMapField(responseTypeName, example.(map[string]interface{})), nil
+		response = g.parser.NewMapField(nil, responseTypeName, example.(map[string]interface{}))
 	}
+
+	// Null samples only count as skipped when no other sample of the field has a type
+	g.parser.reportEmptyFields(nil, response)
+	return response, nil
 }
 
 func (g *ResponseFieldsGenerator) generatedWithoutPaging(responseAllTypeName string, example map[string]interface{}) (Field, error) {
@@ -144,7 +209,7 @@ func (g *ResponseFieldsGenerator) generatedWithoutPaging(responseAllTypeName str
 	delete(example, "ps")
 	delete(example, "total")
 
-	return g.parser.NewMapField(responseAllTypeName, example), nil
+	return g.parser.NewMapField(nil, responseAllTypeName, example), nil
 }
 
 type RequestStructGenerator struct {
@@ -157,14 +222,21 @@ func NewRequestStructGenerator(service *Service, action *Action) *RequestStructG
 }
 
 func (g *RequestStructGenerator) generate() *Statement {
+	_, enums := g.service.enums()
+
 	fields := make([]Code, len(g.action.Params))
 	for i, param := range g.action.Params {
 		// filter out unwanted fields and paging parameters
-		if contains(param.Key, append(skippedRequestFields, "p", "ps")) {
+		if isSkippedParam(param.Key) {
 			continue
 		}
 
-		fields[i] = param.render(g.action.Post)
+		// The versions of the action already describe params that are available in all of them
+		if len(param.Versions) == len(g.action.Versions) {
+			param.Versions = nil
+		}
+
+		fields[i] = param.render(g.action.Post, enums[g.action.Key+"."+param.Key])
 	}
 
 	statement := Commentf("%s %s", g.action.requestTypeName(), g.action.Description)
@@ -172,6 +244,10 @@ func (g *RequestStructGenerator) generate() *Statement {
 		statement.Line()
 		statement.Commentf("Deprecated: this action has been deprecated since version %s", g.action.DeprecatedSince)
 	}
+	if compat := compatibility(g.action.Versions); compat != "" {
+		statement.Line()
+		statement.Comment(compat)
+	}
 	statement.Line()
 
 	statement.Type().Id(g.action.requestTypeName()).Struct(fields...)
@@ -179,12 +255,50 @@ func (g *RequestStructGenerator) generate() *Statement {
 	return statement
 }
 
-func (a *Action) responseStruct(response Field) *Statement {
+// validate generates the Validate method of the request, checking the params against the constraints of the spec.
+//
+// Outputs:
+//
+//	// Validate checks SearchRequest against the constraints of the web service spec
+//	func (r SearchRequest) Validate() error {
+//		v := types.NewValidation("SearchRequest")
+//		v.Required("component", r.Component != "")
+//		for _, value := range r.Severities {
+//			v.OneOf("severities", string(value), "INFO", "MINOR")
+//		}
+//		return v.Err()
+//	}
+func (g *RequestStructGenerator) validate() *Statement {
+	checks := []Code{
+		Id("v").Op(":=").Qual(qualifier(typesPackage), "NewValidation").Call(Lit(g.action.requestTypeName())),
+	}
+	for _, param := range g.action.Params {
+		if isSkippedParam(param.Key) {
+			continue
+		}
+		checks = append(checks, param.checks()...)
+	}
+	checks = append(checks, Return(Id("v").Dot("Err").Call()))
+
+	statement := Commentf("Validate checks %s against the constraints of the web service spec", g.action.requestTypeName())
+	statement.Line()
+	statement.Func().Params(Id("r").Id(g.action.requestTypeName())).Id("Validate").Params().Error().Block(checks...)
+
+	return statement
+}
+
+func (a *Action) responseStruct(response Field, origin string) *Statement {
 	// EmptyField should not be rendered
 	if reflect.TypeOf(response) != reflect.TypeOf(&EmptyField{}) {
 		fields := response.Render(false)
 		statement := Commentf("%s is the response for %s", a.responseTypeName(), a.requestTypeName())
 		statement.Line()
+		if origin != "" {
+			statement.Commentf("Generated from the response example of %s", origin)
+		} else {
+			statement.Comment("No response example could be used, the response is kept as raw JSON")
+		}
+		statement.Line()
 		statement.Type().Add(fields)
 		return statement
 	}
@@ -217,12 +331,14 @@ func (a *Action) responseStructPagingFunc(collection Field) *Statement {
 	return Empty()
 }
 
-func (a *Action) responseAllStruct(collection Field) *Statement {
+func (a *Action) responseAllStruct(collection Field, origin string) *Statement {
 	// EmptyField should not be rendered
 	if reflect.TypeOf(collection) != reflect.TypeOf(&EmptyField{}) {
 		fields := collection.Render(false)
 		statement := Commentf("%s is the collection for %s", a.responseAllTypeName(), a.requestTypeName())
 		statement.Line()
+		statement.Commentf("Generated from the response example of %s", origin)
+		statement.Line()
 		statement.Type().Add(fields)
 		return statement
 	}
@@ -245,55 +361,48 @@ func checkJSONType(body []byte) interface{} {
 	return map[string]interface{}{}
 }
 
-func (a *Action) fetchExample(endpoint string) (interface{}, error) {
-	controller := fmt.Sprintf("api/%s", endpoint)
-	request := ResponseExampleRequest{ID: a.responseTypeName(), RequestID: a.id(), Controller: controller, Action: a.Key}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	req, err := newRequest(request)
-	if err != nil {
-		return nil, fmt.Errorf("could not create request: %+v", err)
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending reqeust: %+v", err)
-	}
-	defer res.Body.Close()
+var (
+	errMissingExample    = errors.New("could not fetch response example")
+	errUnsupportedFormat = errors.New("unsupported response format")
+)
 
-	body, err := ioutil.ReadAll(res.Body)
+// fetchExample returns the decoded response example and the server it was taken from.
+func (a *Action) fetchExample(source Source, controller string) (interface{}, string, error) {
+	body, origin, err := source.ResponseExample(controller, a.Key)
 	if err != nil {
-		return nil, fmt.Errorf("could not read response body: %+v", err)
+		return nil, "", fmt.Errorf("%w: %+v", errMissingExample, err)
 	}
 
 	var responseExample Example
 	err = json.Unmarshal(body, &responseExample)
 	if err != nil {
-		return nil, fmt.Errorf("could not unmarshall body: %+v", err)
+		return nil, "", fmt.Errorf("could not unmarshall body: %+v", err)
 	}
 
 	if responseExample.Format == "json" {
 		// Convert the example JSON string (!!) to a map
 		example := checkJSONType([]byte(responseExample.Example))
 		if example == nil {
-			return nil, fmt.Errorf("无法确定JSON类型：空输入")
+			return nil, "", fmt.Errorf("无法确定JSON类型：空输入")
 		}
 
-		err := json.Unmarshal([]byte(responseExample.Example), &example)
+		// Numbers are kept as written, so integers are told from floats and do not lose precision
+		decoder := json.NewDecoder(strings.NewReader(responseExample.Example))
+		decoder.UseNumber()
+		err := decoder.Decode(&example)
 		if err != nil {
-			return nil, fmt.Errorf("could not marshall example: %+v", err)
+			return nil, "", fmt.Errorf("could not marshall example: %+v", err)
 		}
 
-		return example, nil
+		return example, origin, nil
 	} else if responseExample.Format == "txt" || responseExample.Format == "xml" || responseExample.Format == "svg" || responseExample.Format == "log" || responseExample.Format == "proto" {
 		// parse txt / xml / svg / log response
 		example := map[string]interface{}{
 			"example": responseExample.Example,
 			"format":  responseExample.Format,
 		}
-		return example, nil
+		return example, origin, nil
 	} else {
-		return nil, fmt.Errorf("unsupported response format %s", responseExample.Format)
+		return nil, "", fmt.Errorf("%w %s", errUnsupportedFormat, responseExample.Format)
 	}
 }
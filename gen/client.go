@@ -2,38 +2,73 @@ package main
 
 import (
 	"bytes"
+	_ "embed"
 	"fmt"
 	"go/format"
 	"io"
-	"log"
 	"text/template"
 )
 
 const (
-	clientTemplateName     = "sonarqube.tpl"
-	clientTemplateFileName = "./gen/tpl/sonarqube.tpl"
-	clientFileName         = "sonarqube.go"
+	clientTemplateName = "sonarqube.tpl"
+	typesTemplateName  = "types.tpl"
 )
 
+// Package shared by the type packages of all services, e.g. for the validation errors of requests
+const typesPackage = "types"
+
+//go:embed tpl/sonarqube.tpl
+var defaultClientTemplate string
+
+//go:embed tpl/types.tpl
+var defaultTypesTemplate string
+
 var (
-	clientTemplate = template.Must(template.New(clientTemplateName).ParseFiles(clientTemplateFileName))
+	clientTemplate = template.Must(template.New(clientTemplateName).Funcs(templateFuncs).Parse(defaultClientTemplate))
+	typesTemplate  = template.Must(template.New(typesTemplateName).Funcs(templateFuncs).Parse(defaultTypesTemplate))
 )
 
-func renderClient(in io.Writer, data *Api) error {
+// clientData is what the client template is rendered with
+type clientData struct {
+	*Api
+	Package string
+	// Import path of the shared types package
+	TypesPackage string
+}
+
+func renderClient(in io.Writer, api *Api) error {
+	return renderTemplate(in, clientTemplate, clientFileName(), clientData{Api: api, Package: packageName, TypesPackage: qualifier(typesPackage)})
+}
+
+// renderTypes renders the shared types package, see typesPackage
+func renderTypes(in io.Writer) error {
+	return renderTemplate(in, typesTemplate, typesFileName(), clientData{Package: typesPackage})
+}
+
+func renderTemplate(in io.Writer, tpl *template.Template, fileName string, data clientData) error {
 	buff := bytes.NewBuffer([]byte{})
 
-	if err := clientTemplate.Execute(buff, data); err != nil {
-		return fmt.Errorf("failed to render client: %w", err)
+	if err := tpl.Execute(buff, data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", fileName, err)
 	}
 
 	src := buff.Bytes()
 
 	formatted, err := format.Source(src)
 	if err != nil {
-		log.Printf("failed to format source of sonarqube.go: err:%s", err.Error())
+		report.addError("", "", fmt.Errorf("%w %s: %+v", errFormatFailed, fileName, err))
 		formatted = src
 	}
 
 	_, err = in.Write(formatted)
 	return err
 }
+
+// clientFileName is the name of the file holding the client, named after the root package
+func clientFileName() string {
+	return packageName + ".go"
+}
+
+func typesFileName() string {
+	return fmt.Sprintf("%s/%s_gen.go", typesPackage, typesPackage)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// The modules the client depends on, required up front so they are not looked up by package
+const clientRequirements = `
+require (
+	github.com/go-playground/form/v4 v4.2.1
+	github.com/google/go-querystring v1.1.0
+)
+`
+
+// The client refers to the error responses of the hand-written part of the package, see Client.Do
+const errorResponseStub = `package sonarqube
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func ErrorResponseFrom(resp *http.Response) (error, error) {
+	return fmt.Errorf("status %d", resp.StatusCode), nil
+}
+`
+
+// runGeneratedTests renders the shared types package into a module of its own, with the client when any of the tests
+// belongs to the root package, and runs the given tests of testdata/client in it, e.g. types/validation_test.go.
+// The client depends on modules that are downloaded, the tests are skipped when they cannot be.
+func runGeneratedTests(t *testing.T, tests ...string) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("building the generated code in short mode")
+	}
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not available to build the generated code")
+	}
+
+	previousRoot, previousPackage := importRoot, packageName
+	importRoot, packageName = "example.com/sonarqube", "sonarqube"
+	defer func() { importRoot, packageName = previousRoot, previousPackage }()
+
+	var types bytes.Buffer
+	if err := renderTypes(&types); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"go.mod":        []byte("module " + importRoot + "\n\ngo 1.20\n"),
+		typesFileName(): types.Bytes(),
+	}
+
+	for _, test := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", "client", filepath.FromSlash(test)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[test] = body
+
+		if filepath.Dir(filepath.FromSlash(test)) == "." && files[clientFileName()] == nil {
+			var client bytes.Buffer
+			if err := renderClient(&client, &Api{}); err != nil {
+				t.Fatal(err)
+			}
+			files[clientFileName()] = client.Bytes()
+			files["errors_stub.go"] = []byte(errorResponseStub)
+			files["go.mod"] = append(files["go.mod"], clientRequirements...)
+		}
+	}
+
+	dir := t.TempDir()
+	for name, body := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tidy := exec.Command(goBinary, "mod", "tidy")
+	tidy.Dir = dir
+	if out, err := tidy.CombinedOutput(); err != nil {
+		t.Skipf("could not resolve the dependencies of the generated code: %v\n%s", err, out)
+	}
+
+	run := exec.Command(goBinary, "test", "./...")
+	run.Dir = dir
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Errorf("tests of the generated code failed: %v\n%s", err, out)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	runGeneratedTests(t, "types/validation_test.go")
+}
+
+func TestEncodeForm(t *testing.T) {
+	runGeneratedTests(t, "encode_test.go")
+}
+
+func TestTimeAndDate(t *testing.T) {
+	runGeneratedTests(t, "types/time_test.go")
+}
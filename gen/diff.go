@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// SpecDiff lists the changes of the web API between two versions.
+type SpecDiff struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Changes []SpecChange `json:"changes"`
+}
+
+const (
+	changeAdded      = "added"
+	changeRemoved    = "removed"
+	changeDeprecated = "deprecated"
+	changePost       = "post"
+	changeRequired   = "required"
+	changeResponse   = "response"
+)
+
+type SpecChange struct {
+	Kind    string `json:"kind"`
+	Service string `json:"service"`
+	Action  string `json:"action,omitempty"`
+	Param   string `json:"param,omitempty"`
+	// Field is the JSON path of a changed response field, e.g. component.measures[].value
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Location identifies the changed service, action or param, e.g. api/issues/search?statuses
+func (c SpecChange) Location() string {
+	location := c.Service
+	if c.Action != "" {
+		location += "/" + c.Action
+	}
+	if c.Param != "" {
+		location += "?" + c.Param
+	}
+	if c.Field != "" {
+		location += " " + c.Field
+	}
+	return location
+}
+
+func diffSpecs(from *Spec, to *Spec) *SpecDiff {
+	diff := &SpecDiff{From: from.Version, To: to.Version, Changes: []SpecChange{}}
+
+	oldServices := map[string]Service{}
+	for _, service := range from.Api.Services {
+		oldServices[service.Path] = service
+	}
+	newServices := map[string]Service{}
+	for _, service := range to.Api.Services {
+		newServices[service.Path] = service
+	}
+
+	for _, path := range unionKeys(oldServices, newServices) {
+		oldService, inOld := oldServices[path]
+		newService, inNew := newServices[path]
+		switch {
+		case !inOld:
+			diff.add(SpecChange{Kind: changeAdded, Service: path})
+		case !inNew:
+			diff.add(SpecChange{Kind: changeRemoved, Service: path})
+		default:
+			if !oldService.deprecated() && newService.deprecated() {
+				diff.add(SpecChange{Kind: changeDeprecated, Service: path})
+			}
+			diff.diffActions(from, to, &oldService, &newService)
+		}
+	}
+
+	return diff
+}
+
+// deprecated reports whether all actions of the service are deprecated.
+func (s *Service) deprecated() bool {
+	for _, action := range s.Actions {
+		if action.DeprecatedSince == "" {
+			return false
+		}
+	}
+	return len(s.Actions) > 0
+}
+
+func (d *SpecDiff) add(change SpecChange) {
+	d.Changes = append(d.Changes, change)
+}
+
+func (d *SpecDiff) diffActions(from *Spec, to *Spec, oldService *Service, newService *Service) {
+	oldActions := map[string]Action{}
+	for _, action := range oldService.Actions {
+		oldActions[action.Key] = action
+	}
+	newActions := map[string]Action{}
+	for _, action := range newService.Actions {
+		newActions[action.Key] = action
+	}
+
+	path := newService.Path
+	for _, key := range unionKeys(oldActions, newActions) {
+		oldAction, inOld := oldActions[key]
+		newAction, inNew := newActions[key]
+		switch {
+		case !inOld:
+			d.add(SpecChange{Kind: changeAdded, Service: path, Action: key})
+			continue
+		case !inNew:
+			d.add(SpecChange{Kind: changeRemoved, Service: path, Action: key})
+			continue
+		}
+
+		if oldAction.DeprecatedSince == "" && newAction.DeprecatedSince != "" {
+			d.add(SpecChange{Kind: changeDeprecated, Service: path, Action: key, Detail: fmt.Sprintf("since %s", newAction.DeprecatedSince)})
+		}
+		if oldAction.Post != newAction.Post {
+			d.add(SpecChange{Kind: changePost, Service: path, Action: key, Detail: fmt.Sprintf("%s -> %s", httpMethod(oldAction.Post), httpMethod(newAction.Post))})
+		}
+
+		d.diffParams(path, &oldAction, &newAction)
+
+		if oldAction.HasResponseExample && newAction.HasResponseExample && from.Source != nil && to.Source != nil {
+			d.diffResponses(from, to, newService, &oldAction, &newAction)
+		}
+	}
+}
+
+func (d *SpecDiff) diffParams(path string, oldAction *Action, newAction *Action) {
+	oldParams := map[string]Param{}
+	for _, param := range oldAction.Params {
+		oldParams[param.Key] = param
+	}
+	newParams := map[string]Param{}
+	for _, param := range newAction.Params {
+		newParams[param.Key] = param
+	}
+
+	for _, key := range unionKeys(oldParams, newParams) {
+		oldParam, inOld := oldParams[key]
+		newParam, inNew := newParams[key]
+		switch {
+		case !inOld:
+			d.add(SpecChange{Kind: changeAdded, Service: path, Action: newAction.Key, Param: key})
+		case !inNew:
+			d.add(SpecChange{Kind: changeRemoved, Service: path, Action: newAction.Key, Param: key})
+		default:
+			if oldParam.DeprecatedSince == "" && newParam.DeprecatedSince != "" {
+				d.add(SpecChange{Kind: changeDeprecated, Service: path, Action: newAction.Key, Param: key, Detail: fmt.Sprintf("since %s", newParam.DeprecatedSince)})
+			}
+			if oldParam.Required != newParam.Required {
+				d.add(SpecChange{Kind: changeRequired, Service: path, Action: newAction.Key, Param: key, Detail: fmt.Sprintf("%s -> %s", requiredness(oldParam.Required), requiredness(newParam.Required))})
+			}
+		}
+	}
+}
+
+// diffResponses compares the fields the FieldParser finds in the response examples of both versions.
+func (d *SpecDiff) diffResponses(from *Spec, to *Spec, service *Service, oldAction *Action, newAction *Action) {
+	oldShape, err := responseShape(from.Source, service, oldAction)
+	if err != nil {
+		logf("Not comparing response of %s/%s: %+v\n", service.Path, oldAction.Key, err)
+		return
+	}
+	newShape, err := responseShape(to.Source, service, newAction)
+	if err != nil {
+		logf("Not comparing response of %s/%s: %+v\n", service.Path, newAction.Key, err)
+		return
+	}
+
+	for _, path := range unionKeys(oldShape, newShape) {
+		oldKind, inOld := oldShape[path]
+		newKind, inNew := newShape[path]
+		change := SpecChange{Kind: changeResponse, Service: service.Path, Action: newAction.Key, Field: path}
+		switch {
+		case !inOld:
+			change.Detail = fmt.Sprintf("added %s", newKind)
+		case !inNew:
+			change.Detail = fmt.Sprintf("removed %s", oldKind)
+		case oldKind != newKind:
+			change.Detail = fmt.Sprintf("%s -> %s", oldKind, newKind)
+		default:
+			continue
+		}
+		d.add(change)
+	}
+}
+
+// responseShape maps the JSON path of every field in the response example to its Go type.
+func responseShape(source Source, service *Service, action *Action) (map[string]string, error) {
+	example, _, err := action.fetchExample(source, service.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := NewFieldParser(service, action, overrides.Filter(service.endpoint(), action.Key))
+	field, err := NewResponseFieldsGenerator(parser).generate(action.responseTypeName(), example)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := map[string]string{}
+	collectShape(field, nil, shape)
+	return shape, nil
+}
+
+func collectShape(field Field, path []string, shape map[string]string) {
+	switch f := field.(type) {
+	case *MapField:
+		if len(path) > 0 {
+			shape[joinPath(path)] = "object"
+		}
+		for _, child := range f.fields {
+			collectShape(child, childPath(path, child.Name()), shape)
+		}
+	case *SliceField:
+		shape[joinPath(path)] = "array"
+		collectShape(f.elem, childPath(path, pathElements), shape)
+	case *EmptyField:
+	default:
+		// The Go type is whatever is rendered after the field name
+		code := fmt.Sprintf("%#v", Type().Add(field.Render(false)))
+		shape[joinPath(path)] = strings.TrimSpace(strings.TrimPrefix(code, "type "+strcase.ToCamel(field.Name())))
+	}
+}
+
+func httpMethod(post bool) string {
+	if post {
+		return "POST"
+	}
+	return "GET"
+}
+
+func requiredness(required bool) string {
+	if required {
+		return "required"
+	}
+	return "optional"
+}
+
+// unionKeys returns the keys of both maps, sorted.
+func unionKeys[V any](a map[string]V, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *SpecDiff) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "SonarQube %s -> %s: %d changes\n\n", d.From, d.To, len(d.Changes))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, change := range d.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", change.Kind, change.Location(), change.Detail)
+	}
+	return tw.Flush()
+}
+
+func (d *SpecDiff) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(d)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exampleSource serves JSON response examples by controller and action, e.g. api/issues/search
+type exampleSource map[string]string
+
+func (s exampleSource) Webservices() ([]byte, error) {
+	return nil, fmt.Errorf("no webservices")
+}
+
+func (s exampleSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	example, ok := s[controller+"/"+action]
+	if !ok {
+		return nil, "", fmt.Errorf("no example for %s/%s", controller, action)
+	}
+	body, err := json.Marshal(Example{Format: "json", Example: example})
+	return body, "test", err
+}
+
+func TestDiffSpecs(t *testing.T) {
+	report = &Report{Entries: []ReportEntry{}}
+
+	from := &Spec{Version: "9.9", Api: Api{Services: []Service{
+		{Path: "api/issues", Actions: []Action{
+			{Key: "search", HasResponseExample: true, Params: []Param{{Key: "statuses"}, {Key: "hotspots"}, {Key: "types"}}},
+			{Key: "assign", Params: []Param{{Key: "issue"}}},
+		}},
+		{Path: "api/properties", Actions: []Action{{Key: "index"}}},
+		{Path: "api/favourites", Actions: []Action{{Key: "search"}}},
+	}}, Source: exampleSource{
+		"api/issues/search": `{"total": 1, "issues": [{"key": "a", "line": 1}]}`,
+	}}
+	to := &Spec{Version: "10.4", Api: Api{Services: []Service{
+		{Path: "api/issues", Actions: []Action{
+			{Key: "search", HasResponseExample: true, Params: []Param{{Key: "statuses", DeprecatedSince: "10.4"}, {Key: "impacts"}, {Key: "types", Required: true}}},
+			{Key: "assign", Post: true, DeprecatedSince: "10.2", Params: []Param{{Key: "issue"}}},
+		}},
+		{Path: "api/features", Actions: []Action{{Key: "list"}}},
+		{Path: "api/favourites", Actions: []Action{{Key: "search", DeprecatedSince: "10.0"}}},
+	}}, Source: exampleSource{
+		"api/issues/search": `{"total": 1, "issues": [{"key": "a", "line": "1", "impacts": [{"severity": "HIGH"}]}]}`,
+	}}
+
+	diff := diffSpecs(from, to)
+
+	var got []string
+	for _, change := range diff.Changes {
+		got = append(got, strings.TrimSpace(fmt.Sprintf("%s %s %s", change.Kind, change.Location(), change.Detail)))
+	}
+	want := []string{
+		"deprecated api/favourites",
+		"deprecated api/favourites/search since 10.0",
+		"added api/features",
+		"deprecated api/issues/assign since 10.2",
+		"post api/issues/assign GET -> POST",
+		"removed api/issues/search?hotspots",
+		"added api/issues/search?impacts",
+		"deprecated api/issues/search?statuses since 10.4",
+		"required api/issues/search?types optional -> required",
+		"response api/issues/search issues[].impacts added array",
+		"response api/issues/search issues[].impacts[] added object",
+		"response api/issues/search issues[].impacts[].severity added string",
+		"response api/issues/search issues[].line int64 -> string",
+		"removed api/properties",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes are\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var text bytes.Buffer
+	if err := diff.writeText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text.String(), "SonarQube 9.9 -> 10.4: 14 changes\n") {
+		t.Errorf("text report starts with %q", strings.SplitN(text.String(), "\n", 2)[0])
+	}
+
+	var decoded SpecDiff
+	var out bytes.Buffer
+	if err := diff.writeJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON report is not valid: %+v", err)
+	}
+	if !reflect.DeepEqual(decoded.Changes, diff.Changes) {
+		t.Errorf("JSON report has %d changes, want %d", len(decoded.Changes), len(diff.Changes))
+	}
+}
+
+func TestDiffSpecsMissingExample(t *testing.T) {
+	var log bytes.Buffer
+	previous := logOutput
+	logOutput = &log
+	defer func() { logOutput = previous }()
+
+	service := Service{Path: "api/issues", Actions: []Action{{Key: "search", HasResponseExample: true}}}
+	from := &Spec{Version: "9.9", Api: Api{Services: []Service{service}}, Source: exampleSource{}}
+	to := &Spec{Version: "10.4", Api: Api{Services: []Service{service}}, Source: exampleSource{"api/issues/search": `{}`}}
+
+	if diff := diffSpecs(from, to); len(diff.Changes) != 0 {
+		t.Errorf("got %d changes, want none", len(diff.Changes))
+	}
+	if !strings.Contains(log.String(), "Not comparing response of api/issues/search") {
+		t.Errorf("missing example is not logged, got %q", log.String())
+	}
+}
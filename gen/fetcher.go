@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Performs the requests of all servers, configured with -concurrency, -retries and -timeout
+var fetcher = NewFetcher(8, 3, 15*time.Second)
+
+// Fetcher shares one http.Client between all requests, limits how many of them run at the same time
+// and retries transient failures with exponential backoff.
+type Fetcher struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	slots   chan struct{}
+}
+
+func NewFetcher(concurrency int, retries int, timeout time.Duration) *Fetcher {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Fetcher{
+		client:  &http.Client{Timeout: timeout},
+		retries: retries,
+		backoff: 500 * time.Millisecond,
+		slots:   make(chan struct{}, concurrency),
+	}
+}
+
+// Get returns the body of a successful response to req.
+// Network errors, timeouts, 429 and 5xx responses are retried, other failures are returned right away.
+func (f *Fetcher) Get(req *http.Request) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, retry, err := f.get(req)
+		if err == nil || !retry || attempt >= f.retries {
+			return body, err
+		}
+
+		wait := f.backoff << attempt
+		logf("Retrying %s in %s: %+v\n", req.URL, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+func (f *Fetcher) get(req *http.Request) ([]byte, bool, error) {
+	f.slots <- struct{}{}
+	defer func() { <-f.slots }()
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, false, fmt.Errorf("authorization failed")
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, true, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, req.URL)
+	case resp.StatusCode >= 300:
+		return nil, false, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, req.URL)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, err != nil, err
+}
+
+// cacheSource keeps the response examples of source in a local directory, in the layout of a snapshot,
+// so repeated generations only fetch what is not cached yet. Examples are cached by controller and action,
+// a cache directory should therefore only be used for a single server version.
+type cacheSource struct {
+	dir    string
+	source Source
+}
+
+func newCacheSource(dir string, source Source) *cacheSource {
+	return &cacheSource{dir: dir, source: source}
+}
+
+func (s *cacheSource) Webservices() ([]byte, error) {
+	return s.source.Webservices()
+}
+
+func (s *cacheSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	path := snapshotExamplePath(s.dir, controller, action)
+	if body, origin, err := readExample(path); err == nil {
+		return body, origin, nil
+	} else if !os.IsNotExist(err) {
+		logf("Ignoring cached example of %s/%s: %+v\n", controller, action, err)
+	}
+
+	body, origin, err := s.source.ResponseExample(controller, action)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := writeExample(path, body, origin); err != nil {
+		logf("Could not cache example of %s/%s: %+v\n", controller, action, err)
+	}
+
+	return body, origin, nil
+}
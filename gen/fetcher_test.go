@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestFetcher returns a fetcher that backs off in milliseconds instead of seconds
+func newTestFetcher(concurrency int, retries int) *Fetcher {
+	f := NewFetcher(concurrency, retries, time.Second)
+	f.backoff = time.Millisecond
+	return f
+}
+
+// statusServer answers with the given status codes in turn, and with 200 and body once they are used up
+func statusServer(t *testing.T, body string, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(&calls, 1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestFetcherGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int32
+		err      string
+	}{
+		{name: "success", calls: 1},
+		{name: "retried 5xx and 429", statuses: []int{503, 429}, retries: 3, calls: 3},
+		{name: "retries exhausted", statuses: []int{500, 502, 504}, retries: 2, calls: 3, err: "unexpected status code 504"},
+		{name: "not found", statuses: []int{404}, retries: 3, calls: 1, err: "unexpected status code 404"},
+		{name: "unauthorized", statuses: []int{401}, retries: 3, calls: 1, err: "authorization failed"},
+	}
+
+	var log bytes.Buffer
+	previous := logOutput
+	logOutput = &log
+	defer func() { logOutput = previous }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := statusServer(t, "ok", test.statuses...)
+
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			body, err := newTestFetcher(1, test.retries).Get(req)
+
+			if test.err == "" && (err != nil || string(body) != "ok") {
+				t.Errorf("got %q: %v, want ok", body, err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("failed with %v, want %s", err, test.err)
+			}
+			if *calls != test.calls {
+				t.Errorf("server was called %d times, want %d", *calls, test.calls)
+			}
+		})
+	}
+}
+
+func TestFetcherBackoff(t *testing.T) {
+	var log bytes.Buffer
+	previous := logOutput
+	logOutput = &log
+	defer func() { logOutput = previous }()
+
+	server, _ := statusServer(t, "ok", 500, 500, 500)
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	if _, err := newTestFetcher(1, 3).Get(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The wait doubles with every attempt
+	for _, wait := range []string{"in 1ms", "in 2ms", "in 4ms"} {
+		if !strings.Contains(log.String(), wait) {
+			t.Errorf("no retry %s, got %q", wait, log.String())
+		}
+	}
+}
+
+func TestFetcherConcurrency(t *testing.T) {
+	var running, max int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&max)
+			if current <= seen || atomic.CompareAndSwapInt32(&max, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(2, 0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			if _, err := f.Get(req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Errorf("%d requests ran at the same time, want at most 2", max)
+	}
+}
+
+func TestCacheSource(t *testing.T) {
+	var log bytes.Buffer
+	previousLog, previousFetcher := logOutput, fetcher
+	logOutput, fetcher = &log, newTestFetcher(1, 0)
+	defer func() { logOutput, fetcher = previousLog, previousFetcher }()
+
+	server, calls := statusServer(t, `{"format":"json","example":"{}"}`)
+	dir := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		source := newCacheSource(dir, newHTTPSource(server.URL, "", false))
+		body, origin, err := source.ResponseExample("api/issues", "search")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != `{"format":"json","example":"{}"}` || origin != server.URL {
+			t.Errorf("run %d got %s from %s", i, body, origin)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("server was called %d times, want the cached example to be used", *calls)
+	}
+
+	// A failed fetch is not cached
+	failing, _ := statusServer(t, "", 404)
+	source := newCacheSource(dir, newHTTPSource(failing.URL, "", false))
+	if _, _, err := source.ResponseExample("api/issues", "show"); err == nil {
+		t.Errorf("missing example is served")
+	}
+	if _, _, err := readExample(snapshotExamplePath(dir, "api/issues", "show")); err == nil {
+		t.Errorf("missing example was cached")
+	}
+}
@@ -1,44 +1,139 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	. "github.com/dave/jennifer/jen"
 	"github.com/iancoleman/strcase"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 type FieldParser struct {
 	service   *Service
 	action    *Action
-	overrides map[string]Field
+	overrides ActionOverrides
 }
 
-func NewFieldParser(service *Service, action *Action, overrides map[string]Field) *FieldParser {
+func NewFieldParser(service *Service, action *Action, overrides ActionOverrides) *FieldParser {
 	parser := &FieldParser{service: service, action: action, overrides: overrides}
 
 	if action.hasPaging() {
-		parser.overrides["paging"] = NewStatementField("paging", Qual(qualifier("paging"), "Paging"))
+		parser.overrides["paging"] = &override{field: NewStatementField("paging", Qual(qualifier("paging"), "Paging"))}
 	}
 
 	return parser
 }
 
-func (p FieldParser) parse(name string, value interface{}) Field {
-	if override, ok := p.overrides[name]; ok {
-		return override
+// parse infers the field for value, found at path in the response
+func (p FieldParser) parse(path []string, name string, value interface{}) Field {
+	var keyed *bool
+	if override := p.overrides.lookup(path, name); override != nil {
+		override.match()
+		if override.keyed == nil {
+			return renamed(override.field, name)
+		}
+		keyed = override.keyed
 	}
 	switch value.(type) {
 	case string:
+		// Timestamps and dates are typed, see the Time and Date types of the client
+		if isTimeValue(value.(string)) {
+			return NewStatementField(name, Qual(qualifier(typesPackage), "Time"))
+		} else if isDateValue(value.(string)) {
+			return NewStatementField(name, Qual(qualifier(typesPackage), "Date"))
+		}
 		return &StringField{name: name}
-	case float64:
+	case json.Number:
+		if isIntegral(value.(json.Number)) {
+			return &IntField{name: name}
+		}
 		return &FloatField{name: name}
 	case bool:
 		return &BoolField{name: name}
 	case map[string]interface{}:
-		return p.NewMapField(name, value.(map[string]interface{}))
+		values := value.(map[string]interface{})
+		if keyed != nil && *keyed || keyed == nil && hasDataKeys(values) {
+			return p.NewKeyedMapField(path, name, values)
+		}
+		return p.NewMapField(path, name, values)
 	case []interface{}:
-		return p.NewSliceField(name, value.([]interface{}))
+		return p.NewSliceField(path, name, value.([]interface{}))
+	}
+
+	// The name lets a sample of another type take its place, see FieldParser.merge.
+	// The field is only reported when no sample has a type, see reportEmptyFields
+	return &EmptyField{name: name}
+}
+
+// reportEmptyFields reports the fields at path and below still without a type once all samples are merged,
+// they are missing from the generated struct
+func (p *FieldParser) reportEmptyFields(path []string, field Field) {
+	switch f := field.(type) {
+	case *EmptyField:
+		report.add(ReportEntry{Kind: reportSkippedField, Service: p.service.Path, Action: p.action.Key, Field: joinPath(path), Detail: "cannot infer a type from null"})
+	case *MapField:
+		for _, child := range f.fields {
+			p.reportEmptyFields(childPath(path, child.Name()), child)
+		}
+	case *SliceField:
+		p.reportEmptyFields(childPath(path, pathElements), f.elem)
+	case *KeyedMapField:
+		p.reportEmptyFields(childPath(path, pathAnyKey), f.elem)
+	}
+}
+
+// JSON paths address fields in a response, e.g. component.measures[].value, as a list of segments.
+// The segment "[]" stands for the elements of an array, and in patterns "*" for any object key.
+const (
+	pathElements = "[]"
+	pathAnyKey   = "*"
+)
+
+func childPath(path []string, segment string) []string {
+	child := make([]string, len(path), len(path)+1)
+	copy(child, path)
+	return append(child, segment)
+}
+
+func joinPath(path []string) string {
+	var b strings.Builder
+	for i, segment := range path {
+		if i > 0 && segment != pathElements {
+			b.WriteString(".")
+		}
+		b.WriteString(segment)
 	}
-	return &EmptyField{}
+	return b.String()
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		key := strings.TrimRight(part, "[]")
+		if key != "" {
+			segments = append(segments, key)
+		}
+		for i := len(key); i+len(pathElements) <= len(part); i += len(pathElements) {
+			segments = append(segments, pathElements)
+		}
+	}
+	return segments
+}
+
+func matchPath(pattern []string, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != path[i] && !(segment == pathAnyKey && path[i] != pathElements) {
+			return false
+		}
+	}
+	return true
 }
 
 type Field interface {
@@ -82,6 +177,201 @@ func (f *FloatField) Render(tags bool) *Statement {
 	return output
 }
 
+type IntField struct {
+	name string
+}
+
+func (f *IntField) Name() string {
+	return f.name
+}
+
+func (f *IntField) Render(tags bool) *Statement {
+	output := renderId(f.name).Int64()
+
+	if tags {
+		output.Add(Tag(map[string]string{"json": f.name + ",omitempty"}))
+	}
+
+	return output
+}
+
+// NumberField is a number kept as written, see -mixed-numbers
+type NumberField struct {
+	name string
+}
+
+func (f *NumberField) Name() string {
+	return f.name
+}
+
+func (f *NumberField) Render(tags bool) *Statement {
+	output := renderId(f.name).Qual("encoding/json", "Number")
+
+	if tags {
+		output.Add(Tag(map[string]string{"json": f.name + ",omitempty"}))
+	}
+
+	return output
+}
+
+// The types of fields with both integral and fractional samples, see -mixed-numbers
+const (
+	numberFloat = "float"
+	numberJSON  = "number"
+)
+
+func isIntegral(number json.Number) bool {
+	_, err := strconv.ParseInt(number.String(), 10, 64)
+	return err == nil
+}
+
+// mergeNumbers returns the field of two numeric samples of the same field: an int when both are integral,
+// a float when both have a fraction, and the type chosen with -mixed-numbers when they are mixed.
+// ok is false when either sample is not a number.
+func mergeNumbers(a Field, b Field) (merged Field, ok bool) {
+	a, optionalA := unwrapOptional(a)
+	b, optionalB := unwrapOptional(b)
+	defer func() {
+		if ok && (optionalA || optionalB) {
+			merged = &OptionalField{field: merged}
+		}
+	}()
+
+	kind := func(field Field) string {
+		switch field.(type) {
+		case *IntField:
+			return "int"
+		case *FloatField:
+			return numberFloat
+		case *NumberField:
+			return numberJSON
+		}
+		return ""
+	}
+
+	kindA, kindB := kind(a), kind(b)
+	switch {
+	case kindA == "" || kindB == "":
+		return nil, false
+	case kindA == kindB:
+		return a, true
+	case kindA == numberJSON || kindB == numberJSON || mixedNumbers == numberJSON:
+		return &NumberField{name: a.Name()}, true
+	}
+	return &FloatField{name: a.Name()}, true
+}
+
+// merge returns the field of two samples of the same field at path, the name of a is kept. Objects, arrays and keyed
+// maps are merged recursively, and numbers widened, see mergeNumbers. Other conflicting samples are widened and
+// reported: timestamps and other strings to string, anything else to json.RawMessage.
+func (p *FieldParser) merge(path []string, a Field, b Field) Field {
+	if merged, ok := mergeNumbers(a, b); ok {
+		return merged
+	}
+
+	a, optionalA := unwrapOptional(a)
+	b, optionalB := unwrapOptional(b)
+
+	merged, conflict := p.mergeSamples(path, a, b)
+	if conflict {
+		report.add(ReportEntry{Kind: reportTypeConflict, Service: p.service.Path, Action: p.action.Key, Field: joinPath(path), Detail: fmt.Sprintf("samples of type %s and %s, widened to %s", sampleType(a), sampleType(b), sampleType(merged))})
+	}
+
+	if optionalA || optionalB {
+		return optional(merged)
+	}
+	return merged
+}
+
+// mergeSamples merges two samples which are not both numbers, conflict is true when they had to be widened
+func (p *FieldParser) mergeSamples(path []string, a Field, b Field) (merged Field, conflict bool) {
+	// Samples without a type, like null, take the type of the other
+	if _, ok := b.(*EmptyField); ok {
+		return a, false
+	}
+	if _, ok := a.(*EmptyField); ok {
+		return renamed(b, a.Name()), false
+	}
+
+	switch x := a.(type) {
+	case *MapField:
+		switch y := b.(type) {
+		case *MapField:
+			x.CombineWith(y, func(a Field, b Field) Field {
+				return p.merge(childPath(path, a.Name()), a, b)
+			})
+			return x, false
+		case *KeyedMapField:
+			// An empty object has no keys telling it is keyed
+			if len(x.fields) == 0 {
+				return &KeyedMapField{name: x.name, elem: y.elem}, false
+			}
+		}
+	case *KeyedMapField:
+		switch y := b.(type) {
+		case *KeyedMapField:
+			x.elem = p.merge(childPath(path, pathAnyKey), x.elem, y.elem)
+			return x, false
+		case *MapField:
+			if len(y.fields) == 0 {
+				return x, false
+			}
+		}
+	case *SliceField:
+		if y, ok := b.(*SliceField); ok {
+			switch {
+			case y.empty:
+			case x.empty:
+				x.elem, x.empty = y.elem, false
+			default:
+				x.elem = p.merge(childPath(path, pathElements), x.elem, y.elem)
+			}
+			return x, false
+		}
+	}
+
+	typeA, typeB := sampleType(a), sampleType(b)
+	switch {
+	case typeA == typeB:
+		return a, false
+	case isAnyType(typeA):
+		return a, false
+	case isAnyType(typeB):
+		return renamed(b, a.Name()), false
+	case isStringType(typeA) && isStringType(typeB):
+		// Times read dates as well, other strings cannot be read as either
+		if typeA == "types.Time" && typeB == "types.Date" || typeA == "types.Date" && typeB == "types.Time" {
+			return NewStatementField(a.Name(), Qual(qualifier(typesPackage), "Time")), false
+		}
+		return &StringField{name: a.Name()}, true
+	}
+	return NewStatementField(a.Name(), Qual("encoding/json", "RawMessage")), true
+}
+
+// sampleType describes the Go type of a sample in conflict reports, e.g. string, object or []int64
+func sampleType(field Field) string {
+	switch f := field.(type) {
+	case *MapField:
+		return "object"
+	case *KeyedMapField:
+		return "map[string]" + sampleType(f.elem)
+	case *SliceField:
+		return "[]" + sampleType(f.elem)
+	case *OptionalField:
+		return "*" + sampleType(f.field)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%#v", renamed(field, "").Render(false)))
+}
+
+// isAnyType reports whether the type holds any sample, e.g. a field already widened
+func isAnyType(goType string) bool {
+	return goType == "json.RawMessage" || goType == "interface{}"
+}
+
+func isStringType(goType string) bool {
+	return goType == "string" || goType == "types.Time" || goType == "types.Date"
+}
+
 type BoolField struct {
 	name string
 }
@@ -100,6 +390,51 @@ func (f *BoolField) Render(tags bool) *Statement {
 	return output
 }
 
+// OptionalField is a scalar field missing from some samples of its object, rendered as a pointer so an absent
+// field is told from a zero value, see -pointers.
+type OptionalField struct {
+	field Field
+}
+
+func (f *OptionalField) Name() string {
+	return f.field.Name()
+}
+
+func (f *OptionalField) Render(tags bool) *Statement {
+	output := renderId(f.Name()).Op("*").Add(renamed(f.field, "").Render(false))
+
+	if tags {
+		output.Add(Tag(map[string]string{"json": f.Name() + ",omitempty"}))
+	}
+
+	return output
+}
+
+// optional makes a scalar field optional with -pointers, other fields are returned as is
+func optional(field Field) Field {
+	if !pointers {
+		return field
+	}
+	switch field.(type) {
+	case *StringField, *FloatField, *IntField, *BoolField, *NumberField:
+		return &OptionalField{field: field}
+	case *StatementField:
+		// Only times and dates, other statements are overrides like the paging, which must keep their type,
+		// or can be nil already like json.RawMessage
+		if isStringType(sampleType(field)) {
+			return &OptionalField{field: field}
+		}
+	}
+	return field
+}
+
+func unwrapOptional(field Field) (Field, bool) {
+	if f, ok := field.(*OptionalField); ok {
+		return f.field, true
+	}
+	return field, false
+}
+
 type MapField struct {
 	name      string
 	fields    []Field
@@ -107,13 +442,13 @@ type MapField struct {
 	mutex     sync.Mutex
 }
 
-func (p *FieldParser) NewMapField(name string, values map[string]interface{}) *MapField {
+func (p *FieldParser) NewMapField(path []string, name string, values map[string]interface{}) *MapField {
 	fields := make([]Field, len(values))
 	keys := sortedKeys(values)
 
 	for i, k := range keys {
 		v := values[k]
-		field := p.parse(k, v)
+		field := p.parse(childPath(path, k), k, v)
 
 		fields[i] = field
 	}
@@ -148,8 +483,10 @@ func (f *MapField) Accessors() []string {
 	return keys
 }
 
-// CombineWith adds the fields from other to this field if they do not exist yet
-func (f *MapField) CombineWith(other *MapField) {
+// CombineWith adds the fields from other to this field if they do not exist yet, keeping the fields sorted by name.
+// Fields found in both are merged with merge, see FieldParser.merge. Fields missing from either are optional,
+// see OptionalField.
+func (f *MapField) CombineWith(other *MapField, merge func(a Field, b Field) Field) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
@@ -159,35 +496,105 @@ func (f *MapField) CombineWith(other *MapField) {
 		existing[field.Name()] = i
 	}
 
+	present := make(map[string]bool, len(other.fields))
+	added := false
 	for _, field := range other.fields {
-		if _, ok := existing[field.Name()]; !ok {
-			f.fields = append(f.fields, field)
+		present[field.Name()] = true
+		if i, ok := existing[field.Name()]; ok {
+			f.fields[i] = merge(f.fields[i], field)
+		} else {
+			f.fields = append(f.fields, optional(field))
+			existing[field.Name()] = len(f.fields) - 1
+			added = true
+		}
+	}
+
+	for i, field := range f.fields {
+		if !present[field.Name()] {
+			f.fields[i] = optional(field)
 		}
 	}
+
+	if added {
+		sort.SliceStable(f.fields, func(i, j int) bool {
+			return f.fields[i].Name() < f.fields[j].Name()
+		})
+	}
 }
 
-type SliceField struct {
+// KeyedMapField is an object whose keys are data rather than field names, e.g. the files of a duplication by
+// their reference, rendered as map[string]T.
+type KeyedMapField struct {
 	name string
 	elem Field
 }
 
-func (p *FieldParser) NewSliceField(name string, values []interface{}) *SliceField {
-	var elem Field
+// Object keys that are not identifiers, like numbers, rule keys or keys with spaces, are data rather than field names
+var fieldKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-	if len(values) > 0 {
-		value := values[0]
-		elem = p.parse("", value)
+func hasDataKeys(values map[string]interface{}) bool {
+	for key := range values {
+		if !fieldKey.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
 
-		// Some example arrays have multiple entries, with not every entry containing every field.
-		// If the first entry is an object (MapField), we collect all possibles object keys.
-		if v, ok := elem.(*MapField); ok && len(values) > 1 {
-			for _, other := range values[1:] {
-				v.CombineWith(p.parse("dummy", other).(*MapField))
-			}
+// NewKeyedMapField infers the type of the values by merging all of them, see FieldParser.merge.
+// The values are at the path segment "*", see Overrides.
+func (p *FieldParser) NewKeyedMapField(path []string, name string, values map[string]interface{}) *KeyedMapField {
+	elemPath := childPath(path, pathAnyKey)
+
+	var elem Field
+	for _, key := range sortedKeys(values) {
+		field := p.parse(elemPath, "", values[key])
+		if elem == nil {
+			elem = field
+		} else {
+			elem = p.merge(elemPath, elem, field)
 		}
-	} else {
-		// Assume []string
-		elem = &StringField{}
+	}
+
+	if elem == nil {
+		elem = NewStatementField("", Interface())
+	}
+
+	return &KeyedMapField{name: name, elem: elem}
+}
+
+func (f *KeyedMapField) Name() string {
+	return f.name
+}
+
+func (f *KeyedMapField) Render(tags bool) *Statement {
+	output := renderId(f.name).Map(String()).Add(f.elem.Render(false))
+
+	if tags {
+		output.Add(Tag(map[string]string{"json": f.name + ",omitempty"}))
+	}
+
+	return output
+}
+
+type SliceField struct {
+	name string
+	elem Field
+	// The array is empty in every sample, its elements are assumed to be strings
+	empty bool
+}
+
+// NewSliceField infers the type of the elements by merging all of them, see FieldParser.merge.
+// Some example arrays have multiple entries, with not every entry containing every field.
+func (p *FieldParser) NewSliceField(path []string, name string, values []interface{}) *SliceField {
+	if len(values) == 0 {
+		return &SliceField{name: name, elem: &StringField{}, empty: true}
+	}
+
+	elemPath := childPath(path, pathElements)
+	elem := p.parse(elemPath, "", values[0])
+	for _, other := range values[1:] {
+		elem = p.merge(elemPath, elem, p.parse(elemPath, "", other))
 	}
 
 	return &SliceField{name: name, elem: elem}
@@ -207,10 +614,13 @@ func (f *SliceField) Render(tags bool) *Statement {
 	return output
 }
 
-type EmptyField struct{}
+// EmptyField is a field without a type, like a null sample, it is not rendered
+type EmptyField struct {
+	name string
+}
 
 func (f *EmptyField) Name() string {
-	return ""
+	return f.name
 }
 
 func (f *EmptyField) Render(_ bool) *Statement {
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	. "github.com/dave/jennifer/jen"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestParser() *FieldParser {
+	return &FieldParser{service: &Service{Path: "api/test"}, action: &Action{Key: "search"}, overrides: ActionOverrides{}}
+}
+
+// decodeSample decodes a response sample like fetchExample does, keeping the numbers as written
+func decodeSample(t *testing.T, sample string) interface{} {
+	t.Helper()
+
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(sample))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("invalid sample %s: %+v", sample, err)
+	}
+	return value
+}
+
+// fieldTypes returns the types of the fields of the object, see sampleType
+func fieldTypes(m *MapField) map[string]string {
+	types := map[string]string{}
+	for _, field := range m.fields {
+		if _, ok := field.(*EmptyField); !ok {
+			types[field.Name()] = sampleType(field)
+		}
+	}
+	return types
+}
+
+// conflicts returns the fields reported with a type conflict
+func conflicts() []string {
+	var fields []string
+	for _, entry := range report.Entries {
+		if entry.Kind == reportTypeConflict {
+			fields = append(fields, entry.Field)
+		}
+	}
+	return fields
+}
+
+func TestFieldParserMerge(t *testing.T) {
+	tests := []struct {
+		name         string
+		samples      string
+		mixedNumbers string
+		pointers     bool
+		want         map[string]string
+		conflicts    []string
+	}{
+		{
+			name:      "string and number",
+			samples:   `[{"value": "high"}, {"value": 3}]`,
+			want:      map[string]string{"value": "json.RawMessage"},
+			conflicts: []string{"[].value"},
+		},
+		{
+			name:      "string and timestamp",
+			samples:   `[{"value": "2023-01-02T10:00:00+0100"}, {"value": "soon"}]`,
+			want:      map[string]string{"value": "string"},
+			conflicts: []string{"[].value"},
+		},
+		{
+			name:    "timestamp and date",
+			samples: `[{"value": "2023-01-02"}, {"value": "2023-01-02T10:00:00+0100"}]`,
+			want:    map[string]string{"value": "types.Time"},
+		},
+		{
+			name:    "integral and fractional numbers",
+			samples: `[{"value": 1}, {"value": 1.5}]`,
+			want:    map[string]string{"value": "float64"},
+		},
+		{
+			name:         "integral and fractional numbers kept as written",
+			samples:      `[{"value": 1}, {"value": 1.5}]`,
+			mixedNumbers: numberJSON,
+			want:         map[string]string{"value": "json.Number"},
+		},
+		{
+			name:    "null and object",
+			samples: `[{"value": null}, {"value": {"key": "a"}}]`,
+			want:    map[string]string{"value": "object"},
+		},
+		{
+			name:    "object and null",
+			samples: `[{"value": {"key": "a"}}, {"value": null}]`,
+			want:    map[string]string{"value": "object"},
+		},
+		{
+			name:    "empty and non-empty arrays",
+			samples: `[{"values": []}, {"values": [1, 2]}]`,
+			want:    map[string]string{"values": "[]int64"},
+		},
+		{
+			name:    "non-empty and empty arrays",
+			samples: `[{"values": [true]}, {"values": []}]`,
+			want:    map[string]string{"values": "[]bool"},
+		},
+		{
+			name:      "array and object",
+			samples:   `[{"value": [1]}, {"value": {"key": "a"}}]`,
+			want:      map[string]string{"value": "json.RawMessage"},
+			conflicts: []string{"[].value"},
+		},
+		{
+			name:    "fields missing from a sample",
+			samples: `[{"key": "a"}, {"name": "b"}]`,
+			want:    map[string]string{"key": "string", "name": "string"},
+		},
+		{
+			name:     "fields missing from a sample with -pointers",
+			samples:  `[{"key": "a", "count": 1}, {"key": "b"}]`,
+			pointers: true,
+			want:     map[string]string{"key": "string", "count": "*int64"},
+		},
+		{
+			name:      "nested objects",
+			samples:   `[{"value": {"items": [{"key": 1}]}}, {"value": {"items": [{"key": "a"}]}}]`,
+			want:      map[string]string{"value": "object"},
+			conflicts: []string{"[].value.items[].key"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report = &Report{Entries: []ReportEntry{}}
+			mixedNumbers, pointers = numberFloat, test.pointers
+			if test.mixedNumbers != "" {
+				mixedNumbers = test.mixedNumbers
+			}
+			defer func() {
+				mixedNumbers, pointers = numberFloat, false
+			}()
+
+			samples := decodeSample(t, test.samples).([]interface{})
+			field := newTestParser().NewSliceField(nil, "items", samples)
+
+			elem, ok := field.elem.(*MapField)
+			if !ok {
+				t.Fatalf("elements are %s, want an object", sampleType(field.elem))
+			}
+			if got := fieldTypes(elem); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("fields are %v, want %v", got, test.want)
+			}
+			if got := conflicts(); !reflect.DeepEqual(got, test.conflicts) {
+				t.Errorf("conflicts are %v, want %v", got, test.conflicts)
+			}
+		})
+	}
+}
+
+func TestFieldParserMergeNested(t *testing.T) {
+	report = &Report{Entries: []ReportEntry{}}
+
+	samples := decodeSample(t, `[{"value": null}, {"value": {"key": "a"}}, {"value": {"name": "b", "tags": []}}, {"value": {"tags": ["c"]}}]`).([]interface{})
+	field := newTestParser().NewSliceField(nil, "items", samples)
+
+	value := field.elem.(*MapField).fields
+	if len(value) != 1 || value[0].Name() != "value" {
+		t.Fatalf("fields are %v, want only value", fieldTypes(field.elem.(*MapField)))
+	}
+	object, ok := value[0].(*MapField)
+	if !ok {
+		t.Fatalf("value is %s, want an object", sampleType(value[0]))
+	}
+	want := map[string]string{"key": "string", "name": "string", "tags": "[]string"}
+	if got := fieldTypes(object); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields of value are %v, want %v", got, want)
+	}
+	if got := conflicts(); got != nil {
+		t.Errorf("conflicts are %v, want none", got)
+	}
+}
+
+func TestFieldParserReportsNullFields(t *testing.T) {
+	report = &Report{Entries: []ReportEntry{}}
+
+	example := decodeSample(t, `{"items": [{"value": null}, {"value": {"key": "a"}, "line": null}], "author": null, "rules": {"a b": null}}`)
+	if _, err := NewResponseFieldsGenerator(newTestParser()).generate("SearchResponse", example); err != nil {
+		t.Fatal(err)
+	}
+
+	// The value of the items has a type in another sample, it is not skipped
+	var skipped []string
+	for _, entry := range report.Entries {
+		if entry.Kind == reportSkippedField {
+			skipped = append(skipped, entry.Field)
+		}
+	}
+	if want := []string{"author", "items[].line", "rules.*"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped fields are %v, want %v", skipped, want)
+	}
+}
+
+func TestFieldParserMergeOptionalStatements(t *testing.T) {
+	report = &Report{Entries: []ReportEntry{}}
+	pointers = true
+	defer func() { pointers = false }()
+
+	parser := newTestParser()
+	parser.overrides["paging"] = &override{field: NewStatementField("paging", Qual(qualifier("paging"), "Paging"))}
+	parser.overrides["measures"] = &override{field: NewStatementField("measures", Qual("encoding/json", "RawMessage"))}
+
+	samples := decodeSample(t, `[{"key": "a"}, {"key": "b", "paging": {"total": 1}, "measures": [], "date": "2023-01-02", "count": 1}]`).([]interface{})
+	field := parser.NewSliceField(nil, "items", samples)
+
+	want := map[string]string{"key": "string", "paging": "paging.Paging", "measures": "json.RawMessage", "date": "*types.Date", "count": "*int64"}
+	if got := fieldTypes(field.elem.(*MapField)); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields are %v, want %v", got, want)
+	}
+}
+
+func TestMapFieldCombineWith(t *testing.T) {
+	a := &MapField{name: "a", fields: []Field{&StringField{name: "key"}, &IntField{name: "total"}}}
+	b := &MapField{name: "b", fields: []Field{&FloatField{name: "total"}, &BoolField{name: "active"}}}
+
+	a.CombineWith(b, func(x Field, y Field) Field {
+		merged, ok := mergeNumbers(x, y)
+		if !ok {
+			t.Fatalf("%s and %s are not numbers", sampleType(x), sampleType(y))
+		}
+		return merged
+	})
+
+	var names []string
+	for _, field := range a.fields {
+		names = append(names, field.Name())
+	}
+	if want := []string{"active", "key", "total"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fields are %v, want %v", names, want)
+	}
+	if got := sampleType(a.fields[2]); got != "float64" {
+		t.Errorf("total is %s, want float64", got)
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"component", []string{"component"}},
+		{"component.measures[].value", []string{"component", "measures", "[]", "value"}},
+		{"matrix[][]", []string{"matrix", "[]", "[]"}},
+		{"[].key", []string{"[]", "key"}},
+		{"files.*.name", []string{"files", "*", "name"}},
+	}
+
+	for _, test := range tests {
+		if got := splitPath(test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+		if got := joinPath(test.want); got != test.path {
+			t.Errorf("joinPath(%q) = %q, want %q", test.want, got, test.path)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"component.measures[].value", "component.measures[].value", true},
+		{"component.measures[].value", "component.measures[].metric", false},
+		{"component.*[].value", "component.measures[].value", true},
+		{"component.*.value", "component.measures[].value", false},
+		{"*", "[]", false},
+		{"files.*.name", "files.AXq.name", true},
+		{"component", "component.key", false},
+	}
+
+	for _, test := range tests {
+		if got := matchPath(splitPath(test.pattern), splitPath(test.path)); got != test.want {
+			t.Errorf("matchPath(%q, %q) = %t, want %t", test.pattern, test.path, got, test.want)
+		}
+	}
+}
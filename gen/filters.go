@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// These services and actions cannot/should not be generated, they are excluded unless -default-excludes=false
+var defaultExcludes = []string{
+	"properties",        // unmarshall errors on already deprecated endpoint
+	"favourites",        // deprecated in favour of favorites ;)
+	"paging",            // non-existent, but there to prevent overwriting custom paging
+	"api/sources:index", // returns the raw source, no JSON
+}
+
+// Filters select the services and actions to generate.
+//
+// A rule is a service pattern, optionally followed by an action pattern: api/issues, api/*:search, qualitygates:list.
+// The service pattern is matched against both the path (api/issues) and the endpoint (issues) of the service.
+// A pattern is a glob, or a regular expression when prefixed with re:, e.g. re:^api/(issues|hotspots)$:re:^search.
+// A service regular expression cannot contain a colon.
+//
+// With include rules only the matching services and actions are kept, excluded ones are always dropped.
+// MinVersion drops what was deprecated before it, MaxVersion what was added after it.
+//
+// The default excludes only skip generating the files of a service, the client keeps covering it, see ApplyClient.
+type Filters struct {
+	include        []filterRule
+	exclude        []filterRule
+	defaultExclude []filterRule
+	MinVersion     string
+	MaxVersion     string
+}
+
+type filterRule struct {
+	service pattern
+	// nil when the rule covers all actions of the service
+	action pattern
+}
+
+type pattern func(string) bool
+
+func NewFilters() *Filters {
+	return &Filters{}
+}
+
+// Include adds include rules, see Filters
+func (f *Filters) Include(rules ...string) error {
+	parsed, err := parseFilterRules(rules)
+	f.include = append(f.include, parsed...)
+	return err
+}
+
+// Exclude adds exclude rules, see Filters
+func (f *Filters) Exclude(rules ...string) error {
+	parsed, err := parseFilterRules(rules)
+	f.exclude = append(f.exclude, parsed...)
+	return err
+}
+
+// ExcludeByDefault adds the rules of defaultExcludes, see Filters
+func (f *Filters) ExcludeByDefault(rules ...string) error {
+	parsed, err := parseFilterRules(rules)
+	f.defaultExclude = append(f.defaultExclude, parsed...)
+	return err
+}
+
+func parseFilterRules(rules []string) ([]filterRule, error) {
+	var parsed []filterRule
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		// The action part follows the first colon after the optional re: prefix of the service pattern
+		servicePart, actionPart := rule, ""
+		offset := 0
+		if strings.HasPrefix(rule, "re:") {
+			offset = len("re:")
+		}
+		if i := strings.Index(rule[offset:], ":"); i >= 0 {
+			servicePart, actionPart = rule[:offset+i], rule[offset+i+1:]
+		}
+
+		servicePattern, err := parsePattern(servicePart)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid filter %s: %w", rule, err)
+		}
+		filter := filterRule{service: servicePattern}
+
+		if actionPart != "" {
+			if filter.action, err = parsePattern(actionPart); err != nil {
+				return parsed, fmt.Errorf("invalid filter %s: %w", rule, err)
+			}
+		}
+		parsed = append(parsed, filter)
+	}
+	return parsed, nil
+}
+
+func parsePattern(text string) (pattern, error) {
+	if strings.HasPrefix(text, "re:") {
+		re, err := regexp.Compile(strings.TrimPrefix(text, "re:"))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(text, ""); err != nil {
+		return nil, err
+	}
+	return func(s string) bool {
+		matched, _ := path.Match(text, s)
+		return matched
+	}, nil
+}
+
+func (r filterRule) matchesService(s *Service) bool {
+	return r.service(s.Path) || r.service(s.endpoint())
+}
+
+// matches reports whether the rule covers the action, a rule without action pattern covers all of them
+func (r filterRule) matches(s *Service, action *Action) bool {
+	return r.matchesService(s) && (r.action == nil || r.action(action.Key))
+}
+
+// Apply returns the selected services, with only their selected actions and params.
+// Services without any selected action are dropped.
+func (f *Filters) Apply(services []Service) []Service {
+	return f.apply(services, true)
+}
+
+// ApplyClient returns the services the client covers: the selected ones, together with those only excluded by
+// default, whose files are not generated but are kept, e.g. the hand written paging package.
+func (f *Filters) ApplyClient(services []Service) []Service {
+	return f.apply(services, false)
+}
+
+func (f *Filters) apply(services []Service, defaults bool) []Service {
+	var selected []Service
+	for _, service := range services {
+		s := service
+		s.Actions = nil
+		for _, action := range service.Actions {
+			if !f.selects(&service, &action, defaults) {
+				continue
+			}
+			action.Params = f.params(action.Params)
+			s.Actions = append(s.Actions, action)
+		}
+
+		if len(s.Actions) == 0 {
+			if defaults {
+				logf("Skipping service '%s'\n", service.Path)
+			}
+			continue
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+func (f *Filters) selects(s *Service, action *Action, defaults bool) bool {
+	for _, rule := range f.exclude {
+		if rule.matches(s, action) {
+			return false
+		}
+	}
+	if defaults {
+		for _, rule := range f.defaultExclude {
+			if rule.matches(s, action) {
+				return false
+			}
+		}
+	}
+
+	if len(f.include) > 0 {
+		included := false
+		for _, rule := range f.include {
+			if rule.matches(s, action) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	return f.inVersionRange(action.Since, action.DeprecatedSince)
+}
+
+func (f *Filters) params(params []Param) []Param {
+	var selected []Param
+	for _, param := range params {
+		if f.inVersionRange(param.Since, param.DeprecatedSince) {
+			selected = append(selected, param)
+		}
+	}
+	return selected
+}
+
+func (f *Filters) inVersionRange(since string, deprecatedSince string) bool {
+	if f.MinVersion != "" && deprecatedSince != "" && compareVersions(deprecatedSince, f.MinVersion) < 0 {
+		return false
+	}
+	if f.MaxVersion != "" && since != "" && compareVersions(since, f.MaxVersion) > 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func filterServices() []Service {
+	return []Service{
+		{Path: "api/issues", Actions: []Action{
+			{Key: "search", Since: "3.6", Params: []Param{{Key: "statuses"}, {Key: "hotspots", DeprecatedSince: "9.8"}, {Key: "impacts", Since: "10.2"}}},
+			{Key: "bulk_change", Since: "3.7"},
+			{Key: "set_tags", Since: "5.1", DeprecatedSince: "9.9"},
+		}},
+		{Path: "api/qualitygates", Actions: []Action{{Key: "list"}, {Key: "show"}, {Key: "create"}}},
+		{Path: "api/sources", Actions: []Action{{Key: "index"}, {Key: "show"}}},
+		{Path: "api/properties", Actions: []Action{{Key: "index"}}},
+		{Path: "api/features", Actions: []Action{{Key: "list", Since: "10.2"}}},
+	}
+}
+
+// selected lists the selected actions as service:action
+func selected(services []Service) []string {
+	var actions []string
+	for _, service := range services {
+		for _, action := range service.Actions {
+			actions = append(actions, service.Path+":"+action.Key)
+		}
+	}
+	return actions
+}
+
+func TestParseFilterRules(t *testing.T) {
+	tests := []struct {
+		rule    string
+		service string
+		action  string
+		want    bool
+	}{
+		{"api/issues", "api/issues", "search", true},
+		{"issues", "api/issues", "search", true},
+		{"api/*", "api/issues", "search", true},
+		{"api/issues:search", "api/issues", "search", true},
+		{"api/issues:search", "api/issues", "bulk_change", false},
+		{"api/*:set_*", "api/issues", "set_tags", true},
+		{"re:^api/(issues|hotspots)$", "api/hotspots", "show", true},
+		{"re:^api/(issues|hotspots)$", "api/qualitygates", "show", false},
+		{"re:^api/issues$:re:^(bulk|set)_", "api/issues", "bulk_change", true},
+		{"re:^api/issues$:re:^(bulk|set)_", "api/issues", "search", false},
+		{"qualitygates:re:^(list|show)$", "api/qualitygates", "list", true},
+		{"qualitygates:re:^(list|show)$", "api/qualitygates", "create", false},
+	}
+
+	for _, test := range tests {
+		rules, err := parseFilterRules([]string{test.rule})
+		if err != nil || len(rules) != 1 {
+			t.Errorf("parsing %s gave %d rules: %v", test.rule, len(rules), err)
+			continue
+		}
+		service := &Service{Path: test.service}
+		if got := rules[0].matches(service, &Action{Key: test.action}); got != test.want {
+			t.Errorf("%s matches %s:%s = %t, want %t", test.rule, test.service, test.action, got, test.want)
+		}
+	}
+
+	if rules, err := parseFilterRules([]string{"", "  "}); err != nil || len(rules) != 0 {
+		t.Errorf("empty rules give %d rules: %v", len(rules), err)
+	}
+	for _, rule := range []string{"re:(", "api/[", "api/issues:re:("} {
+		if _, err := parseFilterRules([]string{rule}); err == nil || !strings.Contains(err.Error(), "invalid filter "+rule) {
+			t.Errorf("parsing %s failed with %v", rule, err)
+		}
+	}
+}
+
+func TestFiltersApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		min     string
+		max     string
+		want    []string
+		client  []string
+	}{
+		{
+			name: "default excludes",
+			want: []string{"api/features:list", "api/issues:search", "api/issues:bulk_change", "api/issues:set_tags", "api/qualitygates:list", "api/qualitygates:show", "api/qualitygates:create", "api/sources:show"},
+			client: []string{"api/features:list", "api/issues:search", "api/issues:bulk_change", "api/issues:set_tags", "api/properties:index",
+				"api/qualitygates:list", "api/qualitygates:show", "api/qualitygates:create", "api/sources:index", "api/sources:show"},
+		},
+		{
+			name:    "include",
+			include: []string{"api/issues", "qualitygates:re:^(list|show)$", "properties"},
+			want:    []string{"api/issues:search", "api/issues:bulk_change", "api/issues:set_tags", "api/qualitygates:list", "api/qualitygates:show"},
+			client:  []string{"api/issues:search", "api/issues:bulk_change", "api/issues:set_tags", "api/properties:index", "api/qualitygates:list", "api/qualitygates:show"},
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{"api/issues"},
+			exclude: []string{"api/*:re:^(bulk|set)_"},
+			want:    []string{"api/issues:search"},
+			client:  []string{"api/issues:search"},
+		},
+		{
+			name:    "version bounds",
+			include: []string{"api/issues", "api/features"},
+			min:     "10.0",
+			max:     "10.1",
+			want:    []string{"api/issues:search", "api/issues:bulk_change"},
+			client:  []string{"api/issues:search", "api/issues:bulk_change"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := NewFilters()
+			f.MinVersion, f.MaxVersion = test.min, test.max
+			if err := f.ExcludeByDefault(defaultExcludes...); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Include(test.include...); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Exclude(test.exclude...); err != nil {
+				t.Fatal(err)
+			}
+
+			services := filterServices()
+			sortServices(services)
+			if got := selected(f.Apply(services)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("generated %v, want %v", got, test.want)
+			}
+			if got := selected(f.ApplyClient(services)); !reflect.DeepEqual(got, test.client) {
+				t.Errorf("client covers %v, want %v", got, test.client)
+			}
+		})
+	}
+}
+
+func TestFiltersApplyParams(t *testing.T) {
+	f := NewFilters()
+	f.MinVersion, f.MaxVersion = "9.9", "10.1"
+
+	given := filterServices()[:1]
+	services := f.Apply(given)
+
+	var params []string
+	for _, param := range services[0].Actions[0].Params {
+		params = append(params, param.Key)
+	}
+	if want := []string{"statuses"}; !reflect.DeepEqual(params, want) {
+		t.Errorf("params are %v, want %v", params, want)
+	}
+
+	// The services given are left as they are
+	if len(given[0].Actions[0].Params) != 3 {
+		t.Errorf("the params of the given services were changed")
+	}
+}
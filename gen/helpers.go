@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	. "github.com/dave/jennifer/jen"
 	"github.com/iancoleman/strcase"
+	"io"
 	"net/http"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 )
 
+// Progress and diagnostics are written to logOutput, which is stderr when stdout holds a report, see -diff
+var logOutput io.Writer = os.Stdout
+
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(logOutput, format, args...)
+}
+
+// exit prints the message like the diagnostics, so it is never mixed into a report on stdout, and exits
 func exit(code int, s interface{}) {
-	fmt.Println(s)
+	fmt.Fprintln(logOutput, s)
 	os.Exit(code)
 }
 
@@ -21,6 +32,28 @@ func guard(err error) {
 	}
 }
 
+// parallel calls work for 0 to count-1, on -concurrency goroutines
+func parallel(count int, work func(i int)) {
+	indexes := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency || i == 0; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+}
+
 func contains(needle string, haystack []string) bool {
 	found := false
 	for _, hay := range haystack {
@@ -47,8 +80,8 @@ func renderId(name string) *Statement {
 	return Id(strcase.ToCamel(name))
 }
 
-func newRequest(responseExampleRequest ResponseExampleRequest) (*http.Request, error) {
-	req, err := http.NewRequest("GET", "https://next.sonarqube.com/sonarqube/api/webservices/response_example", nil)
+func newRequest(host string, responseExampleRequest ResponseExampleRequest) (*http.Request, error) {
+	req, err := http.NewRequest("GET", host+responseExampleUrl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -65,8 +98,17 @@ func newRequest(responseExampleRequest ResponseExampleRequest) (*http.Request, e
 	return req, nil
 }
 
+// saveFile writes a generated file, failing with errFormatFailed when the code is not valid Go
+func saveFile(file *File, fileName string) error {
+	buff := bytes.NewBuffer([]byte{})
+	if err := file.Render(buff); err != nil {
+		return fmt.Errorf("%w: %+v", errFormatFailed, err)
+	}
+	return writer.WriteFile(fileName, buff.Bytes())
+}
+
 func qualifier(pkg string) string {
-	return fmt.Sprintf("github.com/shijl0925/go-sonarqube/sonarqube/%s", pkg)
+	return fmt.Sprintf("%s/%s", importRoot, pkg)
 }
 
 func ifTrueGen(ok bool, statement *Statement) *Statement {
@@ -97,6 +139,16 @@ func ifErrorReturn(ok bool) *Statement {
 	}
 }
 
+func ifInvalidReturn(ok bool) *Statement {
+	var result *Statement
+	if ok {
+		result = Return(Nil().Op(",").Nil().Op(",").Err())
+	} else {
+		result = Return(Nil().Op(",").Err())
+	}
+	return If(Err().Op(":=").Id("r").Dot("Validate").Call(), Err().Op("!=").Nil()).Block(result)
+}
+
 func genReturnWithError(hasRetVal bool, retId string) *Statement {
 	if hasRetVal {
 		return Return(Id(retId).Op(",").Id("resp").Op(",").Nil())
@@ -1,121 +1,384 @@
 // This small program is used to generate request structs and services for the SonarQube API.
 // It expects a JSON file with the same structure as returned by `https://next.sonarqube.com/sonarqube/web_api/api/webservices/list`.
+// The definitions and response examples are fetched from a server, or replayed from a snapshot recorded with -record.
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Api struct {
 	Services []Service `json:"webServices"`
+	// Versions lists the versions of the merged specs, see mergeSpecs.
+	Versions []string `json:"-"`
 }
 
-// These endpoints cannot/should not be generated
-var skippedEndpoints = []string{
-	"duplications", // numeric map keys cause parse errors
-	"properties",   // unmarshall errors on already deprecated endpoint
-	"favourites",   // deprecated in favour of favorites ;)
-	"paging",       // non-existent, but there to prevent overwriting custom paging
-}
+// Overrides of the inferred response field types, see NewOverrides and -overrides
+var overrides = NewOverrides()
+
+// Selection of the services and actions to generate, see Filters
+var filters = NewFilters()
 
 // These fields don't need to be in each request struct
 var skippedRequestFields = []string{}
 
-const packageName = "sonarqube"
-
 const (
 	webservicesUrl     = "/api/webservices/list"
 	includeInternalUrl = "?include_internals=true"
+	responseExampleUrl = "/api/webservices/response_example"
 )
 
 var (
-	host     string
-	internal bool
-	help     bool
-	auth     string
+	// Import path of the generated root package, the type packages are placed below it
+	importRoot  string
+	packageName string
+	outputDir   string
+
+	host          string
+	internal      bool
+	help          bool
+	auth          string
+	snapshot      string
+	record        string
+	fallback      string
+	public        string
+	merge         string
+	diff          string
+	diffJSON      bool
+	templates     string
+	overridesFile string
+	mixedNumbers  string
+	pointers      bool
+	nestedDepth   int
+	sharedTypes   bool
+
+	reportFile string
+	strict     bool
+	check      bool
+	force      bool
+
+	concurrency int
+	retries     int
+	timeout     time.Duration
+	cache       string
+
+	include            string
+	exclude            string
+	useDefaultExcludes bool
 )
 
 func main() {
 	var mainFlagsSet = flag.NewFlagSet("", flag.PanicOnError)
+	mainFlagsSet.StringVar(&importRoot, "module", "github.com/shijl0925/go-sonarqube/sonarqube", "import path of the generated root package")
+	mainFlagsSet.StringVar(&packageName, "package", "sonarqube", "name of the generated root package")
+	mainFlagsSet.StringVar(&outputDir, "output", "sonarqube", "directory of the generated root package, the type packages are generated in its subdirectories")
 	mainFlagsSet.StringVar(&host, "host", "http://localhost:9000", "SonarQube server")
 	mainFlagsSet.BoolVar(&internal, "internal", false, "generate code for internal methods (default: false)")
 	mainFlagsSet.BoolVar(&help, "help", false, "show usage")
 	mainFlagsSet.StringVar(&auth, "auth", "", "the header Authorization value,example: Basic YWRtaW46YWRtaW4=")
+	mainFlagsSet.StringVar(&snapshot, "snapshot", "", "generate offline from a snapshot directory instead of the server, example: snapshots/9.9.0.65466")
+	mainFlagsSet.StringVar(&record, "record", "", "record a snapshot of the server into a directory per server version below this directory, without generating")
+	mainFlagsSet.StringVar(&fallback, "fallback-snapshot", "", "snapshot directory to take response examples from when the server has none")
+	mainFlagsSet.StringVar(&public, "public-host", "https://next.sonarqube.com/sonarqube", "public SonarQube server to take response examples from as a last resort, empty to disable")
+	mainFlagsSet.StringVar(&merge, "merge", "", "comma separated specs to merge into a client covering several versions: snapshot directories, server urls or version labelled JSON files, example: 9.9=spec-9.9.json,snapshots/10.4.0.87286")
+	mainFlagsSet.StringVar(&diff, "diff", "", "report the changes between two specs instead of generating, given like -merge, example: snapshots/9.9.0.65466,snapshots/10.4.0.87286")
+	mainFlagsSet.BoolVar(&diffJSON, "json", false, "write the -diff report as JSON (default: false)")
+	mainFlagsSet.StringVar(&templates, "templates", "", "directory with templates replacing the generated code: sonarqube.tpl for the client, types.tpl for the shared types package, service.tpl per service, action.tpl per action")
+	mainFlagsSet.StringVar(&overridesFile, "overrides", "", "YAML or JSON file with field type overrides and skipped endpoints and request fields")
+	mainFlagsSet.StringVar(&mixedNumbers, "mixed-numbers", numberFloat, "type of response fields with both integral and fractional samples: float for float64, number for json.Number")
+	mainFlagsSet.BoolVar(&pointers, "pointers", false, "generate optional request params and response fields missing from some samples as pointers, so zero values are told from absent ones (default: false)")
+	mainFlagsSet.IntVar(&nestedDepth, "nested-depth", -1, "depth up to which objects nested in responses get named types, e.g. SearchResponseIssue, 0 keeps them all inline, -1 names all of them")
+	mainFlagsSet.BoolVar(&sharedTypes, "shared-types", false, "move response objects with the same shape in several services into the shared types package, this regenerates all services (default: false)")
+	mainFlagsSet.StringVar(&include, "include", "", "comma separated services or actions to generate, globs or re: regular expressions, example: api/issues,api/qualitygates:re:^(list|show)$")
+	mainFlagsSet.StringVar(&exclude, "exclude", "", "comma separated services or actions not to generate, given like -include, example: api/*:*_deprecated")
+	mainFlagsSet.BoolVar(&useDefaultExcludes, "default-excludes", true, "do not generate the files of the services and actions that cannot be generated, see defaultExcludes, the client keeps covering them (default: true)")
+	mainFlagsSet.StringVar(&filters.MinVersion, "min-version", "", "skip the actions and params deprecated before this version, example: 9.9")
+	mainFlagsSet.StringVar(&filters.MaxVersion, "max-version", "", "skip the actions and params added after this version, example: 10.4")
+	mainFlagsSet.StringVar(&reportFile, "report", "", "write the problems found during generation to this JSON file")
+	mainFlagsSet.BoolVar(&strict, "strict", false, "exit with an error when anything could not be generated as is, not only on errors (default: false)")
+	mainFlagsSet.BoolVar(&check, "check", false, "write nothing, but print how the generated files differ from the ones on disk and fail when they do (default: false)")
+	mainFlagsSet.BoolVar(&force, "force", false, "regenerate all services, also those unchanged since the last run according to the manifest (default: false)")
+	mainFlagsSet.IntVar(&concurrency, "concurrency", 8, "number of services generated and requests sent at the same time")
+	mainFlagsSet.IntVar(&retries, "retries", 3, "number of retries of requests failing with a network error, 429 or 5xx, with exponential backoff")
+	mainFlagsSet.DurationVar(&timeout, "timeout", 15*time.Second, "timeout of each request")
+	mainFlagsSet.StringVar(&cache, "cache", "", "directory caching the response examples fetched from -host and its fallbacks, use one per server version")
 	mainFlagsSet.Parse(os.Args[1:])
 	if help {
 		mainFlagsSet.Usage()
 		os.Exit(0)
 	}
-
-	apiUrl := host + webservicesUrl
-	if internal {
-		apiUrl += includeInternalUrl
+	importRoot = strings.TrimSuffix(importRoot, "/")
+	if mixedNumbers != numberFloat && mixedNumbers != numberJSON {
+		exit(1, fmt.Sprintf("invalid -mixed-numbers '%s', expected %s or %s", mixedNumbers, numberFloat, numberJSON))
 	}
+	fetcher = NewFetcher(concurrency, retries, timeout)
 
-	req, err := http.NewRequest("GET", apiUrl, nil)
-	if err != nil {
-		fmt.Printf("failed to create request: %v", err)
+	if err := loadTemplates(templates); err != nil {
 		exit(1, err)
 	}
-	if auth != "" {
-		req.Header.Add("Authorization", auth)
+
+	if useDefaultExcludes {
+		guard(filters.ExcludeByDefault(defaultExcludes...))
 	}
-	client := http.Client{
-		Timeout: 15 * time.Second, // 设置超时时间
+	guard(filters.Include(strings.Split(include, ",")...))
+	guard(filters.Exclude(strings.Split(exclude, ",")...))
+
+	if overridesFile != "" {
+		if err := overrides.Load(overridesFile); err != nil {
+			exit(1, err)
+		}
 	}
-	resp, err := client.Do(req)
 
-	if resp.StatusCode == 401 {
-		fmt.Println("Authorization failed")
-		os.Exit(1)
+	if diff != "" {
+		locations := strings.Split(diff, ",")
+		if len(locations) != 2 {
+			exit(1, "-diff expects exactly two specs")
+		}
+
+		// Stdout holds only the report
+		logOutput = os.Stderr
+
+		from, err := loadSpec(locations[0])
+		if err != nil {
+			exit(1, fmt.Sprintf("failed to load spec: %+v", err))
+		}
+		to, err := loadSpec(locations[1])
+		if err != nil {
+			exit(1, fmt.Sprintf("failed to load spec: %+v", err))
+		}
+
+		from.Api.Services = filters.Apply(from.Api.Services)
+		to.Api.Services = filters.Apply(to.Api.Services)
+		specDiff := diffSpecs(from, to)
+		if diffJSON {
+			err = specDiff.writeJSON(os.Stdout)
+		} else {
+			err = specDiff.writeText(os.Stdout)
+		}
+		guard(err)
+		return
 	}
 
-	if err != nil {
-		fmt.Printf("failed to fetch api definitions：%v", err)
-		exit(1, err)
+	var source Source
+	if snapshot != "" {
+		snapshotSource, err := openSnapshot(snapshot)
+		if err != nil {
+			exit(1, fmt.Sprintf("failed to open snapshot: %+v", err))
+		}
+		source = snapshotSource
+	} else {
+		server := newHTTPSource(host, auth, internal)
+		source = newExampleSources(server)
+		if cache != "" {
+			source = newCacheSource(cache, source)
+		}
+
+		if record != "" {
+			dir, err := recordSnapshot(server, source, record)
+			if err != nil {
+				exit(1, fmt.Sprintf("failed to record snapshot: %+v", err))
+			}
+			fmt.Printf("recorded snapshot in %s\n", dir)
+			return
+		}
 	}
-	defer resp.Body.Close()
 
 	var api Api
-	if err := json.NewDecoder(resp.Body).Decode(&api); err != nil {
-		fmt.Errorf("could not decode response: %+v", err)
+	if merge != "" {
+		var specs []*Spec
+		var sources []Source
+		for _, location := range strings.Split(merge, ",") {
+			spec, err := loadSpec(location)
+			if err != nil {
+				exit(1, fmt.Sprintf("failed to load spec: %+v", err))
+			}
+			specs = append(specs, spec)
+		}
+
+		api = mergeSpecs(specs)
+		specVersions = api.Versions
+
+		// Prefer the examples of the newest version that has them
+		for i := len(specs) - 1; i >= 0; i-- {
+			if specs[i].Source != nil {
+				sources = append(sources, specs[i].Source)
+			}
+		}
+		source = newFallbackSource(append(sources, source)...)
+	} else {
+		body, err := source.Webservices()
+		if err != nil {
+			exit(1, fmt.Sprintf("failed to fetch api definitions: %+v", err))
+		}
+
+		if err := json.Unmarshal(body, &api); err != nil {
+			exit(1, fmt.Sprintf("could not decode response: %+v", err))
+		}
 	}
+	// The client also covers the services excluded by default, as it always did, only their files are not generated
+	clientApi := api
+	clientApi.Services = filters.ApplyClient(api.Services)
+	api.Services = filters.Apply(api.Services)
+	sortServices(clientApi.Services)
+	sortServices(api.Services)
 
-	// create sonarqube.go
-	path := fmt.Sprintf("%s/%s", packageName, clientFileName)
-	file, err := os.Create(path)
-	if err != nil {
-		fmt.Errorf("failed to create file：%w", err)
+	var checker *checkWriter
+	if check {
+		checker = newCheckWriter(outputDir)
+		writer = checker
 	}
 
+	// create sonarqube.go
+	client := bytes.NewBuffer([]byte{})
 	if err := renderClient(
-		file,
-		&api,
+		client,
+		&clientApi,
 	); err != nil {
-		fmt.Printf("failed to render client: %s", err.Error())
+		report.addError("", "", err)
+	} else if err := writer.WriteFile(fmt.Sprintf("%s/%s", outputDir, clientFileName()), client.Bytes()); err != nil {
+		exit(1, fmt.Sprintf("failed to create file: %+v", err))
 	}
 
-	wg := &sync.WaitGroup{}
-	for _, service := range api.Services {
-		fmt.Printf("processing service at path %s\n", service.Path)
+	types := bytes.NewBuffer([]byte{})
+	if err := renderTypes(types); err != nil {
+		report.addError("", "", err)
+	} else if err := writer.WriteFile(fmt.Sprintf("%s/%s", outputDir, typesFileName()), types.Bytes()); err != nil {
+		exit(1, fmt.Sprintf("failed to create file: %+v", err))
+	}
 
-		s := service
+	generator, err := generatorFingerprint()
+	if err != nil {
+		exit(1, fmt.Sprintf("failed to fingerprint generator: %+v", err))
+	}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := s.process(packageName)
-			if err != nil {
-				fmt.Printf("Error processing service at path %s: %+v\n", s.Path, err)
+	// Unchanged services are skipped, unless forced or checked, a check compares every file.
+	// Shared types depend on all services, so they are all generated.
+	manifest := loadManifest(outputDir)
+	incremental := !force && !check && !sharedTypes
+
+	// The services are parsed first, and rendered once the types shared between them are known
+	parsed := make([]*ParsedService, len(api.Services))
+	fingerprints := make([]string, len(api.Services))
+	examples := make([]*hashingSource, len(api.Services))
+	parallel(len(api.Services), func(i int) {
+		s := &api.Services[i]
+		logf("processing service at path %s\n", s.Path)
+
+		fingerprints[i] = s.fingerprint(generator)
+		if entry, ok := manifest.upToDate(outputDir, s, fingerprints[i], source); ok && incremental {
+			logf("Skipping unchanged service at path %s\n", s.Path)
+			for _, problem := range entry.Problems {
+				report.add(problem)
 			}
-		}()
+			return
+		}
+
+		examples[i] = newHashingSource(source)
+		parsed[i] = s.parse(examples[i])
+	})
+
+	if sharedTypes {
+		shared, err := NewSharedTypes(types.Bytes())
+		if err != nil {
+			exit(1, err)
+		}
+		var services []*ParsedService
+		for _, p := range parsed {
+			if p != nil {
+				services = append(services, p)
+			}
+		}
+		shared.dedupe(services)
+		if err := shared.save(outputDir); err != nil {
+			report.addError("", "", fmt.Errorf("could not save shared types: %w", err))
+		}
+	} else if err := removeStaleShared(fmt.Sprintf("%s/%s", outputDir, sharedTypesFileName())); err != nil {
+		report.addError("", "", fmt.Errorf("could not remove stale shared types: %w", err))
+	}
+
+	parallel(len(api.Services), func(i int) {
+		s := &api.Services[i]
+		if parsed[i] == nil {
+			return
+		}
+
+		err := parsed[i].render(outputDir)
+		if err != nil {
+			report.addError(s.Path, "", err)
+		}
+
+		// Failed services and those missing examples are regenerated on every run, until that is solved
+		problems := report.entries(s.Path)
+		if err != nil || hasFailures(problems) {
+			manifest.remove(s)
+		} else {
+			manifest.set(s, ManifestService{Fingerprint: fingerprints[i], Examples: examples[i].fingerprint(), Problems: problems})
+		}
+	})
+
+	if !check {
+		manifest.Generator = generator
+		manifest.retain(api.Services)
+		if err := manifest.save(outputDir); err != nil {
+			report.addError("", "", fmt.Errorf("could not save manifest: %w", err))
+		}
+	}
+
+	if err := removeStaleFiles(outputDir, clientApi.Services); err != nil {
+		report.addError("", "", fmt.Errorf("could not remove stale files: %w", err))
+	}
+
+	for _, unmatched := range unmatchedOverrides(parsed) {
+		logf("Override %s did not match any field\n", unmatched)
+	}
+
+	guard(report.writeSummary(os.Stdout))
+	if reportFile != "" {
+		guard(report.writeJSON(reportFile))
+	}
+	if checker != nil {
+		if count := checker.writeDiffs(os.Stdout); count > 0 {
+			exit(1, fmt.Sprintf("%d generated files are not up to date", count))
+		}
+		fmt.Println("generated files are up to date")
+	}
+	if report.Failed() {
+		exit(1, "generation failed, see the report above")
+	}
+	if strict && report.Degraded() {
+		exit(1, "generation degraded, failing because of -strict")
+	}
+}
+
+func sortServices(services []Service) {
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Path < services[j].Path
+	})
+}
+
+// newExampleSources returns the chain response examples are taken from:
+// the target server, then the fallback snapshot, then the public server.
+func newExampleSources(server *httpSource) Source {
+	sources := []Source{server}
+
+	if fallback != "" {
+		snapshotSource, err := openSnapshot(fallback)
+		if err != nil {
+			exit(1, fmt.Sprintf("failed to open fallback snapshot: %+v", err))
+		}
+		sources = append(sources, snapshotSource)
+	}
+
+	if public != "" && public != server.host {
+		sources = append(sources, newHTTPSource(public, "", false))
 	}
 
-	wg.Wait()
+	return newFallbackSource(sources...)
 }
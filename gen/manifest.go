@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// The manifest is stored in the output directory, and lists the fingerprints of the generated services
+const manifestFileName = ".sonarqube-gen.json"
+
+// Manifest records what each service was generated from, so unchanged services are skipped on the next run.
+type Manifest struct {
+	// Fingerprint of the generator binary, the overrides file and the templates
+	Generator string                     `json:"generator"`
+	Services  map[string]ManifestService `json:"services"`
+	mutex     sync.Mutex
+}
+
+type ManifestService struct {
+	// Fingerprint of the spec of the service, together with the generator and the options affecting its code
+	Fingerprint string `json:"fingerprint"`
+	// Fingerprint of the response examples the service was generated from
+	Examples string `json:"examples"`
+	// Problems reported while generating the service, reported again when it is skipped
+	Problems []ReportEntry `json:"problems,omitempty"`
+}
+
+func loadManifest(output string) *Manifest {
+	manifest := &Manifest{Services: map[string]ManifestService{}}
+
+	body, err := ioutil.ReadFile(filepath.Join(output, manifestFileName))
+	if err != nil {
+		return manifest
+	}
+	if err := json.Unmarshal(body, manifest); err != nil || manifest.Services == nil {
+		logf("Ignoring invalid manifest %s: %+v\n", filepath.Join(output, manifestFileName), err)
+		return &Manifest{Services: map[string]ManifestService{}}
+	}
+	return manifest
+}
+
+// upToDate returns the entry of the service when it was generated from the same fingerprint, and its files still exist.
+// When the examples are cheap to read again, i.e. from a snapshot or the cache, they must be unchanged as well.
+func (m *Manifest) upToDate(output string, s *Service, fingerprint string, source Source) (ManifestService, bool) {
+	m.mutex.Lock()
+	entry, ok := m.Services[s.Path]
+	m.mutex.Unlock()
+	if !ok || entry.Fingerprint != fingerprint {
+		return entry, false
+	}
+	if isLocalSource(source) && examplesFingerprint(s, source) != entry.Examples {
+		return entry, false
+	}
+
+	for _, fileName := range s.fileNames(output) {
+		if _, err := os.Stat(fileName); err != nil {
+			return entry, false
+		}
+	}
+	return entry, true
+}
+
+func (m *Manifest) set(s *Service, entry ManifestService) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Services[s.Path] = entry
+}
+
+// hasFailures reports whether any of the problems is worth another try on the next run
+func hasFailures(problems []ReportEntry) bool {
+	for _, problem := range problems {
+		if problem.failed() || problem.Kind == reportMissingExample {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manifest) remove(s *Service) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.Services, s.Path)
+}
+
+// retain drops the services that are not generated anymore
+func (m *Manifest) retain(services []Service) {
+	paths := map[string]bool{}
+	for i := range services {
+		paths[services[i].Path] = true
+	}
+	for path := range m.Services {
+		if !paths[path] {
+			delete(m.Services, path)
+		}
+	}
+}
+
+func (m *Manifest) save(output string) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(output, manifestFileName), append(body, '\n'))
+}
+
+// generatorFingerprint covers everything outside of the spec that changes the generated code of all services:
+// the generator itself, the overrides file and the templates.
+func generatorFingerprint() (string, error) {
+	h := sha256.New()
+
+	files := []string{}
+	if executable, err := os.Executable(); err == nil {
+		files = append(files, executable)
+	} else {
+		return "", fmt.Errorf("could not find generator executable: %w", err)
+	}
+	if overridesFile != "" {
+		files = append(files, overridesFile)
+	}
+	if templates != "" {
+		names, err := filepath.Glob(filepath.Join(templates, "*.tpl"))
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+
+	for _, fileName := range files {
+		if err := hashFile(h, fileName); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(h hash.Hash, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(h, "%s\n", filepath.Base(fileName))
+	_, err = io.Copy(h, file)
+	return err
+}
+
+// fingerprint covers the spec of the service, including the merged versions, and the options changing its code.
+func (s *Service) fingerprint(generator string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", generator)
+	fmt.Fprintf(h, "%s\n%s\n%q\n%q\n%s %t %d %t\n", importRoot, packageName, specVersions, skippedRequestFields, mixedNumbers, pointers, nestedDepth, sharedTypes)
+	// The sources of the examples, the examples themselves are only compared when they are local, see upToDate
+	fmt.Fprintf(h, "%q %q %q %q %q %q\n", host, snapshot, fallback, public, cache, merge)
+
+	spec, _ := json.Marshal(s)
+	h.Write(spec)
+
+	// The merged versions are not part of the JSON
+	fmt.Fprintf(h, "\n%q\n", s.Versions)
+	for _, action := range s.Actions {
+		fmt.Fprintf(h, "%s %q\n", action.Key, action.Versions)
+		for _, param := range action.Params {
+			fmt.Fprintf(h, "%s %q\n", param.Key, param.Versions)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileNames returns the files generated for the service
+func (s *Service) fileNames(output string) []string {
+	endpoint := s.endpoint()
+	return []string{
+		fmt.Sprintf("%s/%s/%s_gen.go", output, endpoint, endpoint),
+		fmt.Sprintf("%s/%s_gen.go", output, endpoint),
+	}
+}
+
+// hashingSource fingerprints the response examples taken from source, in the order they are requested.
+type hashingSource struct {
+	Source
+	hash hash.Hash
+}
+
+func newHashingSource(source Source) *hashingSource {
+	return &hashingSource{Source: source, hash: sha256.New()}
+}
+
+func (s *hashingSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	body, origin, err := s.Source.ResponseExample(controller, action)
+	if err == nil {
+		fmt.Fprintf(s.hash, "%s/%s %s %d\n", controller, action, origin, len(body))
+		s.hash.Write(body)
+	}
+	return body, origin, err
+}
+
+// examplesFingerprint reads the response examples of the service again, as parsing the service does
+func examplesFingerprint(s *Service, source Source) string {
+	examples := newHashingSource(source)
+	for _, action := range s.Actions {
+		if action.HasResponseExample {
+			examples.ResponseExample(s.Path, action.Key)
+		}
+	}
+	return examples.fingerprint()
+}
+
+// isLocalSource reports whether the examples of source are read from disk, so they are cheap to read again
+func isLocalSource(source Source) bool {
+	switch s := source.(type) {
+	case *snapshotSource, *cacheSource:
+		return true
+	case *fallbackSource:
+		for _, fallback := range s.sources {
+			if !isLocalSource(fallback) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (s *hashingSource) fingerprint() string {
+	return hex.EncodeToString(s.hash.Sum(nil))
+}
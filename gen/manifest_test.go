@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServiceFingerprint(t *testing.T) {
+	service := func() *Service {
+		return &Service{Path: "api/issues", Actions: []Action{{Key: "search", Params: []Param{{Key: "statuses"}}}}}
+	}
+	base := service().fingerprint("generator")
+	if again := service().fingerprint("generator"); again != base {
+		t.Fatalf("fingerprint is not stable")
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *Service) func()
+	}{
+		{"generator", nil},
+		{"spec", func(s *Service) func() {
+			s.Actions[0].Post = true
+			return func() {}
+		}},
+		{"merged versions", func(s *Service) func() {
+			s.Actions[0].Params[0].Versions = []string{"9.9"}
+			return func() {}
+		}},
+		{"pointers", func(s *Service) func() {
+			pointers = true
+			return func() { pointers = false }
+		}},
+		{"skipped request fields", func(s *Service) func() {
+			previous := skippedRequestFields
+			skippedRequestFields = []string{"organization"}
+			return func() { skippedRequestFields = previous }
+		}},
+		{"host", func(s *Service) func() {
+			previous := host
+			host = "https://sonar.example.com"
+			return func() { host = previous }
+		}},
+		{"snapshot", func(s *Service) func() {
+			previous := snapshot
+			snapshot = "snapshots/10.4"
+			return func() { snapshot = previous }
+		}},
+	}
+
+	for _, test := range tests {
+		s, generator, restore := service(), "generator", func() {}
+		if test.change == nil {
+			generator = "other generator"
+		} else {
+			restore = test.change(s)
+		}
+		changed := s.fingerprint(generator)
+		restore()
+
+		if changed == base {
+			t.Errorf("changing the %s keeps the fingerprint", test.name)
+		}
+	}
+	if again := service().fingerprint("generator"); again != base {
+		t.Errorf("the options were not restored")
+	}
+}
+
+// writeSnapshot writes a snapshot with the given JSON response examples by controller and action
+func writeSnapshot(t *testing.T, dir string, examples map[string]string) *snapshotSource {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, snapshotInfoFileName), []byte(`{"version": "10.4", "host": "https://sonar.example.com"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for path, example := range examples {
+		body := []byte(`{"format": "json", "example": ` + example + `}`)
+		if err := writeExample(filepath.Join(dir, snapshotExamplesDir, filepath.FromSlash(path)), body, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source, err := openSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func TestManifestUpToDate(t *testing.T) {
+	s := &Service{Path: "api/issues", Actions: []Action{{Key: "search", HasResponseExample: true}, {Key: "assign"}}}
+	output := t.TempDir()
+	for _, fileName := range s.fileNames(output) {
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(generatedMarker), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := writeSnapshot(t, t.TempDir(), map[string]string{"api/issues/search": `"{\"total\": 1}"`})
+	examples := examplesFingerprint(s, source)
+
+	manifest := &Manifest{Services: map[string]ManifestService{}}
+	if _, ok := manifest.upToDate(output, s, "a", source); ok {
+		t.Errorf("service missing from the manifest is up to date")
+	}
+
+	manifest.set(s, ManifestService{Fingerprint: "a", Examples: examples, Problems: []ReportEntry{{Kind: reportSkippedField, Service: s.Path}}})
+	if err := manifest.save(output); err != nil {
+		t.Fatal(err)
+	}
+	manifest = loadManifest(output)
+
+	entry, ok := manifest.upToDate(output, s, "a", source)
+	if !ok {
+		t.Fatalf("unchanged service is not up to date")
+	}
+	if len(entry.Problems) != 1 || entry.Problems[0].Kind != reportSkippedField {
+		t.Errorf("problems of the service are %v, want the skipped field", entry.Problems)
+	}
+	if _, ok := manifest.upToDate(output, s, "b", source); ok {
+		t.Errorf("service with another fingerprint is up to date")
+	}
+
+	changed := writeSnapshot(t, t.TempDir(), map[string]string{"api/issues/search": `"{\"total\": 2}"`})
+	if _, ok := manifest.upToDate(output, s, "a", changed); ok {
+		t.Errorf("service with changed snapshot examples is up to date")
+	}
+	// Examples read from a server are not compared, reading them again costs as much as generating
+	if _, ok := manifest.upToDate(output, s, "a", newHTTPSource("https://sonar.example.com", "", false)); !ok {
+		t.Errorf("examples from a server are compared")
+	}
+
+	if err := os.Remove(s.fileNames(output)[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest.upToDate(output, s, "a", source); ok {
+		t.Errorf("service with a missing file is up to date")
+	}
+}
+
+func TestManifestRetain(t *testing.T) {
+	manifest := &Manifest{Services: map[string]ManifestService{"api/issues": {}, "api/favourites": {}}}
+	manifest.retain([]Service{{Path: "api/issues"}, {Path: "api/features"}})
+
+	if _, ok := manifest.Services["api/favourites"]; ok || len(manifest.Services) != 1 {
+		t.Errorf("services are %v, want only api/issues", manifest.Services)
+	}
+}
+
+func TestHasFailures(t *testing.T) {
+	tests := []struct {
+		kind string
+		want bool
+	}{
+		{reportError, true},
+		{reportFormatFailed, true},
+		{reportMissingExample, true},
+		{reportSkippedField, false},
+		{reportNearMatch, false},
+	}
+
+	for _, test := range tests {
+		if got := hasFailures([]ReportEntry{{Kind: test.kind}}); got != test.want {
+			t.Errorf("hasFailures(%s) = %t, want %t", test.kind, got, test.want)
+		}
+	}
+}
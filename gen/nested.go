@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+)
+
+// NestedTypes hoists the objects nested in a response into named types, named after their path below the response,
+// e.g. the elements of SearchResponse.Issues[].Flows become SearchResponseIssueFlow. See -nested-depth.
+type NestedTypes struct {
+	// Type names already used in the type package of the service
+	used map[string]bool
+	// Names of the hoisted types by the path of their object, e.g. SearchResponse.issues[].flows[]
+	hoisted map[string]string
+	// Declarations of the hoisted types, in the order they were found
+	declarations []Code
+}
+
+func NewNestedTypes(s *Service) *NestedTypes {
+	used := map[string]bool{}
+	for _, action := range s.Actions {
+		used[action.requestTypeName()] = true
+		used[action.responseTypeName()] = true
+		used[action.responseAllTypeName()] = true
+	}
+	enums, _ := s.enums()
+	for _, enum := range enums {
+		used[enum.Name] = true
+	}
+
+	return newNestedTypes(used)
+}
+
+// newNestedTypes hoists into a package where the given type names are used, the hoisted names are added to them
+func newNestedTypes(used map[string]bool) *NestedTypes {
+	return &NestedTypes{used: used, hoisted: map[string]string{}}
+}
+
+// hoist replaces the nested objects of the response field named typeName by named types,
+// and returns their declarations. Objects deeper than -nested-depth stay inline.
+// Hoisting again with the same name reuses the types of the objects at the same paths,
+// e.g. for the collection of a paged response. Different objects deriving the same name get a numeric suffix.
+func (n *NestedTypes) hoist(field Field, typeName string) []Code {
+	n.declarations = nil
+
+	switch f := field.(type) {
+	case *MapField:
+		n.hoistFields(f, typeName, typeName, 1)
+	case *SliceField:
+		// The response is an array, its elements are at depth 1 as well
+		f.elem = n.hoistElem(f.elem, typeName+"Item", typeName+"[]", fmt.Sprintf("an element of %s", typeName), 1)
+	}
+
+	return n.declarations
+}
+
+// hoistFields hoists the objects in the fields of m, path is the path of m
+func (n *NestedTypes) hoistFields(m *MapField, typeName string, path string, depth int) {
+	for i, field := range m.fields {
+		accessor := fmt.Sprintf("%s.%s", typeName, strcase.ToCamel(field.Name()))
+		fieldPath := path + "." + field.Name()
+
+		switch f := field.(type) {
+		case *MapField:
+			if name, ok := n.declare(f, typeName+strcase.ToCamel(f.Name()), fieldPath, fmt.Sprintf("the type of %s", accessor), depth); ok {
+				m.fields[i] = NewStatementField(f.Name(), Id(name))
+			}
+		case *SliceField:
+			f.elem = n.hoistElem(f.elem, typeName+singular(strcase.ToCamel(f.Name())), fieldPath+"[]", fmt.Sprintf("an element of %s", accessor), depth)
+		case *KeyedMapField:
+			f.elem = n.hoistElem(f.elem, typeName+singular(strcase.ToCamel(f.Name())), fieldPath+"{}", fmt.Sprintf("a value of %s", accessor), depth)
+		}
+	}
+}
+
+// hoistElem hoists the elements of slices and keyed maps, the elements of nested slices share the name
+func (n *NestedTypes) hoistElem(elem Field, typeName string, path string, description string, depth int) Field {
+	switch f := elem.(type) {
+	case *MapField:
+		if name, ok := n.declare(f, typeName, path, description, depth); ok {
+			return NewStatementField(f.Name(), Id(name))
+		}
+	case *SliceField:
+		f.elem = n.hoistElem(f.elem, typeName, path+"[]", description, depth)
+	case *KeyedMapField:
+		f.elem = n.hoistElem(f.elem, typeName, path+"{}", description, depth)
+	}
+	return elem
+}
+
+// declare adds the declaration of a named type for the object at path, after hoisting its own nested objects.
+// It returns the name of the type, unless the object is too deep to be hoisted.
+func (n *NestedTypes) declare(m *MapField, typeName string, path string, description string, depth int) (string, bool) {
+	if nestedDepth >= 0 && depth > nestedDepth {
+		return "", false
+	}
+
+	if name, ok := n.hoisted[path]; ok {
+		return name, true
+	}
+
+	name := typeName
+	for i := 2; n.used[name]; i++ {
+		name = fmt.Sprintf("%s%d", typeName, i)
+	}
+	n.used[name] = true
+	n.hoisted[path] = name
+
+	// The type is declared before the types nested in it
+	index := len(n.declarations)
+	n.declarations = append(n.declarations, nil)
+	n.hoistFields(m, name, path, depth+1)
+
+	statement := Commentf("%s is %s", name, description)
+	statement.Line()
+	statement.Type().Add((&MapField{name: name, fields: m.fields}).Render(false))
+	n.declarations[index] = statement
+
+	return name, true
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"os"
+	"strings"
+	"testing"
+)
+
+// declaredTypes returns the rendered declarations by the name of their type
+func declaredTypes(t *testing.T, declarations []Code) map[string]string {
+	t.Helper()
+
+	types := map[string]string{}
+	for _, declaration := range declarations {
+		code := fmt.Sprintf("%#v", declaration)
+		name := ""
+		for _, line := range strings.Split(code, "\n") {
+			if strings.HasPrefix(line, "type ") {
+				name = strings.Fields(line)[1]
+				break
+			}
+		}
+		if name == "" {
+			t.Fatalf("not a type declaration:\n%s", code)
+		}
+		types[name] = code
+	}
+	return types
+}
+
+// fieldType returns the type the field is rendered with, e.g. []MeasuresResponsePeriod
+func fieldType(m *MapField, name string) string {
+	for _, field := range m.fields {
+		if field.Name() == name {
+			return sampleType(field)
+		}
+	}
+	return ""
+}
+
+func TestNestedTypesHoist(t *testing.T) {
+	nestedDepth = -1
+	defer func() { nestedDepth = 0 }()
+
+	example := `{"component": {"key": "a", "measures": [{"metric": "m"}]}, "period": {"mode": "days"}, "periods": [{"mode": "days", "index": 1}], "paging": {"total": 1}}`
+	parser := newTestParser()
+	response := parser.NewMapField(nil, "MeasuresResponse", decodeSample(t, example).(map[string]interface{}))
+
+	nested := newNestedTypes(map[string]bool{"MeasuresResponse": true, "MeasuresResponsePeriod": true})
+	types := declaredTypes(t, nested.hoist(response, "MeasuresResponse"))
+
+	for _, name := range []string{"MeasuresResponseComponent", "MeasuresResponseComponentMeasure", "MeasuresResponsePeriod2", "MeasuresResponsePeriod3", "MeasuresResponsePaging"} {
+		if _, ok := types[name]; !ok {
+			t.Errorf("type %s is not declared, got %d types", name, len(types))
+		}
+	}
+	if got := fieldType(response, "period"); got != "MeasuresResponsePeriod2" {
+		t.Errorf("period is %s, want MeasuresResponsePeriod2", got)
+	}
+	if got := fieldType(response, "periods"); got != "[]MeasuresResponsePeriod3" {
+		t.Errorf("periods is %s, want []MeasuresResponsePeriod3", got)
+	}
+	if strings.Contains(types["MeasuresResponsePeriod2"], "Index") || !strings.Contains(types["MeasuresResponsePeriod3"], "Index") {
+		t.Errorf("the period types are mixed up:\n%s\n%s", types["MeasuresResponsePeriod2"], types["MeasuresResponsePeriod3"])
+	}
+
+	// The collection of all pages reuses the types of the response
+	values := decodeSample(t, example).(map[string]interface{})
+	delete(values, "paging")
+	all := parser.NewMapField(nil, "MeasuresResponseAll", values)
+	if declarations := nested.hoist(all, "MeasuresResponse"); len(declarations) != 0 {
+		t.Errorf("hoisting again declares %d types, want none", len(declarations))
+	}
+	if got := fieldType(all, "periods"); got != "[]MeasuresResponsePeriod3" {
+		t.Errorf("periods of all pages is %s, want []MeasuresResponsePeriod3", got)
+	}
+}
+
+func TestNestedTypesDepth(t *testing.T) {
+	nestedDepth = 1
+	defer func() { nestedDepth = 0 }()
+
+	example := `{"component": {"key": "a", "measures": [{"metric": "m"}]}}`
+	response := newTestParser().NewMapField(nil, "ShowResponse", decodeSample(t, example).(map[string]interface{}))
+
+	types := declaredTypes(t, newNestedTypes(map[string]bool{}).hoist(response, "ShowResponse"))
+	if len(types) != 1 || !strings.Contains(types["ShowResponseComponent"], "Measures []struct") {
+		t.Errorf("want only ShowResponseComponent with inline measures, got %v", types)
+	}
+}
+
+func TestNestedTypesArrayResponse(t *testing.T) {
+	nestedDepth = -1
+	defer func() { nestedDepth = 0 }()
+
+	response := newTestParser().NewSliceField(nil, "ListResponse", decodeSample(t, `[{"key": "a"}]`).([]interface{}))
+
+	types := declaredTypes(t, newNestedTypes(map[string]bool{}).hoist(response, "ListResponse"))
+	if _, ok := types["ListResponseItem"]; !ok || sampleType(response) != "[]ListResponseItem" {
+		t.Errorf("want []ListResponseItem, got %s and %d types", sampleType(response), len(types))
+	}
+}
+
+func TestNestedTypesAcrossActions(t *testing.T) {
+	nestedDepth = -1
+	previousRoot, previousPackage := importRoot, packageName
+	importRoot, packageName = "example.com/sonarqube", "sonarqube"
+	defer func() { nestedDepth, importRoot, packageName = 0, previousRoot, previousPackage }()
+
+	// search hoists SearchResponse.responseIssue, search_response hoists SearchResponseResponse.issue,
+	// both derive the name SearchResponseResponseIssue
+	service := &Service{Path: "api/issues", Actions: []Action{{Key: "search"}, {Key: "search_response"}}}
+	parsed := &ParsedService{service: service}
+	for i, example := range []string{`{"responseIssue": {"key": "a"}}`, `{"issue": {"line": 1}}`} {
+		action := service.Actions[i]
+		response := newTestParser().NewMapField(nil, action.responseTypeName(), decodeSample(t, example).(map[string]interface{}))
+		parsed.actions = append(parsed.actions, &ParsedAction{action: action, response: response, responseAll: &EmptyField{}})
+	}
+
+	output := t.TempDir()
+	if err := parsed.render(output); err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(service.fileNames(output)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := map[string]int{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "type ") {
+			declared[strings.Fields(line)[1]]++
+		}
+	}
+	for name, count := range declared {
+		if count > 1 {
+			t.Errorf("%s is declared %d times", name, count)
+		}
+	}
+	if declared["SearchResponseResponseIssue"] != 1 || declared["SearchResponseResponseIssue2"] != 1 {
+		t.Errorf("declared %v, want SearchResponseResponseIssue and SearchResponseResponseIssue2", declared)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Every generated source file contains this comment, only such files are ever removed
+const generatedMarker = "// AUTOMATICALLY GENERATED, DO NOT EDIT BY HAND!"
+
+// Writes the generated files, to disk or, with -check, into memory
+var writer OutputWriter = diskWriter{}
+
+// OutputWriter writes and removes the generated files.
+type OutputWriter interface {
+	WriteFile(fileName string, data []byte) error
+	Remove(fileName string) error
+}
+
+type diskWriter struct{}
+
+func (diskWriter) WriteFile(fileName string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data)
+}
+
+func (diskWriter) Remove(fileName string) error {
+	return os.Remove(fileName)
+}
+
+// checkWriter writes nothing, but compares every file with the one on disk and keeps the differences.
+type checkWriter struct {
+	mutex sync.Mutex
+	// the output directory, the diff headers name files relative to it
+	root string
+	// unified diffs by file name
+	diffs map[string]string
+}
+
+func newCheckWriter(root string) *checkWriter {
+	return &checkWriter{root: root, diffs: map[string]string{}}
+}
+
+// header returns the name of the file in a diff header, e.g. a/issues/issues_gen.go, also for an absolute output directory
+func (w *checkWriter) header(prefix string, fileName string) string {
+	if rel, err := filepath.Rel(w.root, fileName); err == nil && !strings.HasPrefix(rel, "..") {
+		fileName = rel
+	}
+	return prefix + strings.TrimLeft(filepath.ToSlash(fileName), "/")
+}
+
+func (w *checkWriter) WriteFile(fileName string, data []byte) error {
+	current, err := ioutil.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	from := w.header("a/", fileName)
+	if os.IsNotExist(err) {
+		from = "/dev/null"
+	}
+	w.add(fileName, unifiedDiff(from, w.header("b/", fileName), string(current), string(data)))
+	return nil
+}
+
+// Remove records the removal of a file. Directories are only removed once empty, which they never get when checking.
+func (w *checkWriter) Remove(fileName string) error {
+	current, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil
+	}
+
+	w.add(fileName, unifiedDiff(w.header("a/", fileName), "/dev/null", string(current), ""))
+	return nil
+}
+
+func (w *checkWriter) add(fileName string, diff string) {
+	if diff == "" {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.diffs[fileName] = diff
+}
+
+// writeDiffs writes the differences sorted by file name, and returns the number of differing files
+func (w *checkWriter) writeDiffs(out io.Writer) int {
+	fileNames := make([]string, 0, len(w.diffs))
+	for fileName := range w.diffs {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		fmt.Fprint(out, w.diffs[fileName])
+	}
+	return len(fileNames)
+}
+
+// writeFileAtomic writes data to a temporary file next to fileName and renames it into place,
+// so an interrupted generation never leaves a truncated file behind.
+func writeFileAtomic(fileName string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// removeStaleFiles removes the generated files of services the client does not cover anymore: their <endpoint>_gen.go
+// in the output directory and the type package directory. Failed services and those excluded by default are among
+// the given ones, so their previous files are kept, and the shared types package is always generated. Files without the generated marker, like the paging package, are never touched.
+func removeStaleFiles(output string, services []Service) error {
+	endpoints := map[string]bool{typesPackage: true}
+	for i := range services {
+		endpoints[services[i].endpoint()] = true
+	}
+
+	entries, err := ioutil.ReadDir(output)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if !endpoints[name] {
+				if err := removeStaleDir(filepath.Join(output, name)); err != nil {
+					return err
+				}
+			}
+		} else if strings.HasSuffix(name, "_gen.go") && !endpoints[strings.TrimSuffix(name, "_gen.go")] {
+			if err := removeGenerated(filepath.Join(output, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeStaleDir removes the generated files of a type package, and the directory when nothing else is left in it.
+func removeStaleDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), "_gen.go") {
+			if err := removeGenerated(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) == 0 {
+		logf("Removing stale directory %s\n", dir)
+		return writer.Remove(dir)
+	}
+	return nil
+}
+
+func removeGenerated(fileName string) error {
+	body, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(body, []byte(generatedMarker)) {
+		return nil
+	}
+
+	if check {
+		logf("Would remove stale file %s\n", fileName)
+	} else {
+		logf("Removing stale file %s\n", fileName)
+	}
+	return writer.Remove(fileName)
+}
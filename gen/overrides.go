@@ -1,16 +1,27 @@
 package main
 
-func NewOverrides() Overrides {
-	var overrides Overrides = make(map[string]map[string]map[string]Field)
+import (
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func NewOverrides() *Overrides {
+	overrides := &Overrides{entries: make(map[string]map[string]map[string]*override)}
 
 	// Possible string->float overrides. Uncomment which are needed.
 	//overrides.Add("ce", "component", "analysisId", &FloatField{name: "analysisId"})
 	//overrides.Add("ce", "task", "analysisId", &FloatField{name: "analysisId"})
 	//overrides.Add("user_tokens", "generate", "token", &FloatField{"token"})
 	//overrides.Add("components", "show", "version", &FloatField{name: "version"})
-	//overrides.Add("measures", "component", "value", &FloatField{name: "value"})
-	//overrides.Add("measures", "component_tree", "value", &FloatField{name: "value"})
-	//overrides.Add("measures", "search_history", "value", &FloatField{name: "value"})
+	//overrides.Add("measures", "component", "component.measures[].value", &FloatField{name: "value"})
+	//overrides.Add("measures", "component_tree", "baseComponent.measures[].value", &FloatField{name: "value"})
+	//overrides.Add("measures", "component_tree", "components[].measures[].value", &FloatField{name: "value"})
+	//overrides.Add("measures", "search_history", "measures[].history[].value", &FloatField{name: "value"})
 	//overrides.Add("metrics", "search", "id", &FloatField{name: "id"})
 	//overrides.Add("project_links", "search", "id", &FloatField{name: "id"})
 	//overrides.Add("project_pull_requests", "list", "key", &FloatField{name: "key"})
@@ -18,17 +29,19 @@ func NewOverrides() Overrides {
 	//overrides.Add("qualitygates", "create", "warning", &FloatField{name: "warning"})
 	overrides.Add("qualitygates", "get_by_project", "id", &FloatField{name: "id"})
 	//overrides.Add("qualitygates", "list", "error", &FloatField{name: "error"})
-	//overrides.Add("qualitygates", "project_status", "actualValue", &FloatField{name: "actualValue"})
-	//overrides.Add("qualitygates", "project_status", "errorThreshold", &FloatField{name: "errorThreshold"})
+	//overrides.Add("qualitygates", "project_status", "projectStatus.conditions[].actualValue", &FloatField{name: "actualValue"})
+	//overrides.Add("qualitygates", "project_status", "projectStatus.conditions[].errorThreshold", &FloatField{name: "errorThreshold"})
 	//overrides.Add("qualitygates", "show", "error", &FloatField{name: "error"})
 
-	// TODO: the actives field of the search response should not be a struct field, but a map[string]SomeStruct field, which is not supported yet.
-	//overrides.Add("rules", "search", "value", &FloatField{name: "value"})
-	//overrides.Add("rules", "search", "defaultValue", &FloatField{name: "defaultValue"})
+	// The actives of the search response are keyed by rule, see KeyedMapField
+	//overrides.Add("rules", "search", "actives.*[].params[].value", &FloatField{name: "value"})
+	//overrides.Add("rules", "search", "rules[].params[].defaultValue", &FloatField{name: "defaultValue"})
 
-	//overrides.Add("rules", "show", "value", &FloatField{name: "value"})
-	//overrides.Add("rules", "show", "defaultValue", &FloatField{name: "defaultValue"})
+	//overrides.Add("rules", "show", "actives[].params[].value", &FloatField{name: "value"})
+	//overrides.Add("rules", "show", "rule.params[].defaultValue", &FloatField{name: "defaultValue"})
 	//overrides.Add("settings", "show", "defaultValue", &FloatField{name: "defaultValue"})
+	// The installed plugins are keyed by plugin key, which look like field names
+	overrides.AddKeyed("system", "info", "Plugins", true)
 	overrides.Add("user_groups", "create", "id", &FloatField{name: "id"})
 	overrides.Add("user_groups", "search", "id", &FloatField{name: "id"})
 	//overrides.Add("user_tokens", "generate", "token", &FloatField{name: "token"})
@@ -36,24 +49,237 @@ func NewOverrides() Overrides {
 	return overrides
 }
 
-type Overrides map[string]map[string]map[string]Field
+// Overrides replace the inferred type of response fields, per endpoint and action.
+// A field is addressed by its name, which matches at any depth, or by a JSON path pattern
+// such as component.measures[].value or actives.*[].params[].value, see splitPath.
+// An override replaces the whole subtree of the field, e.g. with an existing Go type:
+//
+//	overrides.Add("measures", "component", "component.period", NewStatementField("period", Qual("github.com/acme/sonar", "Period")))
+type Overrides struct {
+	entries map[string]map[string]map[string]*override
+}
+
+type override struct {
+	field Field
+	// Set by AddKeyed, forces an object to be a map or a struct instead of replacing its type
+	keyed *bool
+	// where the override was declared, for reporting
+	origin string
+	// Set by the parsers of all services at once, see match
+	matched bool
+	mutex   sync.Mutex
+}
+
+// match records that the override was applied to a field
+func (o *override) match() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.matched = true
+}
+
+func (o *override) isMatched() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.matched
+}
 
-func (o Overrides) Add(endpoint string, actionKey string, name string, override Field) {
-	if _, ok := o[endpoint]; !ok {
-		o[endpoint] = make(map[string]map[string]Field)
+// ActionOverrides are the overrides of a single action, by field name or path pattern
+type ActionOverrides map[string]*override
+
+// lookup returns the override for the field at path: the first override with a matching path pattern,
+// otherwise the one for the name of the field.
+func (o ActionOverrides) lookup(path []string, name string) *override {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		if isPathPattern(key) {
+			keys = append(keys, key)
+		}
 	}
-	if _, ok := o[endpoint][actionKey]; !ok {
-		o[endpoint][actionKey] = map[string]Field{}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if matchPath(splitPath(key), path) {
+			return o[key]
+		}
 	}
-	o[endpoint][actionKey][name] = override
 
+	if entry, ok := o[name]; ok && name != "" && !isPathPattern(name) {
+		return entry
+	}
+	return nil
+}
+
+func isPathPattern(key string) bool {
+	return strings.ContainsAny(key, ".[*")
 }
 
-func (o Overrides) Filter(endpoint string, actionKey string) map[string]Field {
-	if endpointEntries, ok := o[endpoint]; ok {
+// renamed returns the override field with the name of the field it replaces, which differs for path patterns
+func renamed(field Field, name string) Field {
+	switch f := field.(type) {
+	case *StringField:
+		return &StringField{name: name}
+	case *FloatField:
+		return &FloatField{name: name}
+	case *IntField:
+		return &IntField{name: name}
+	case *BoolField:
+		return &BoolField{name: name}
+	case *NumberField:
+		return &NumberField{name: name}
+	case *StatementField:
+		return NewStatementField(name, f.statement)
+	case *OptionalField:
+		return &OptionalField{field: renamed(f.field, name)}
+	}
+	return field
+}
+
+func (o *Overrides) Add(endpoint string, actionKey string, name string, override Field) {
+	o.add(endpoint, actionKey, name, override, "NewOverrides")
+}
+
+// AddKeyed forces the object at name to be a map keyed by data, when keyed, or a struct, see KeyedMapField.
+// Unlike Add, the types of the values are still inferred.
+func (o *Overrides) AddKeyed(endpoint string, actionKey string, name string, keyed bool) {
+	o.addKeyed(endpoint, actionKey, name, keyed, "NewOverrides")
+}
+
+func (o *Overrides) addKeyed(endpoint string, actionKey string, name string, keyed bool, origin string) {
+	o.add(endpoint, actionKey, name, nil, origin)
+	o.entries[endpoint][actionKey][name].keyed = &keyed
+}
+
+func (o *Overrides) add(endpoint string, actionKey string, name string, field Field, origin string) {
+	if _, ok := o.entries[endpoint]; !ok {
+		o.entries[endpoint] = make(map[string]map[string]*override)
+	}
+	if _, ok := o.entries[endpoint][actionKey]; !ok {
+		o.entries[endpoint][actionKey] = map[string]*override{}
+	}
+	o.entries[endpoint][actionKey][name] = &override{field: field, origin: origin}
+}
+
+func (o *Overrides) Filter(endpoint string, actionKey string) ActionOverrides {
+	filtered := ActionOverrides{}
+	if endpointEntries, ok := o.entries[endpoint]; ok {
 		if actionEntries, ok := endpointEntries[actionKey]; ok {
-			return actionEntries
+			for name, entry := range actionEntries {
+				filtered[name] = entry
+			}
+		}
+	}
+	return filtered
+}
+
+// unmatchedOverrides lists the overrides that were not applied to any field, so stale entries get noticed.
+// Only the actions parsed from a response example are considered, the overrides of services skipped as unchanged
+// or left out by the filters never had a chance to match.
+func unmatchedOverrides(parsed []*ParsedService) []string {
+	var unmatched []string
+	for _, service := range parsed {
+		if service == nil {
+			continue
+		}
+		for _, action := range service.actions {
+			for name, entry := range action.overrides {
+				if !entry.isMatched() {
+					unmatched = append(unmatched, fmt.Sprintf("%s/%s %s (%s)", service.service.endpoint(), action.action.Key, name, entry.origin))
+				}
+			}
 		}
 	}
-	return map[string]Field{}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// OverridesFile is the YAML or JSON file given with -overrides, for example:
+//
+//	skippedEndpoints: [favourites, "api/issues:re:^(bulk|set)_"]
+//	skippedRequestFields: [organization]
+//	overrides:
+//	  - {endpoint: qualitygates, action: get_by_project, field: id, type: float}
+//	  - {endpoint: measures, action: component, field: "component.measures[].value", type: float}
+//	  - {endpoint: measures, action: component, field: component.period, type: github.com/acme/sonar.Period}
+//	  - {endpoint: rules, action: search, field: actives, type: keyed}
+//
+// The field is a name or a JSON path pattern, see Overrides.
+// The type is one of string, float, int, bool, number (json.Number), map, slice or raw (json.RawMessage),
+// or a Go type given as <import path>.<name>. The types keyed and object keep the inferred types
+// of the values of an object, but force it to be a map keyed by data or a struct, see KeyedMapField.
+type OverridesFile struct {
+	SkippedEndpoints     []string         `yaml:"skippedEndpoints"`
+	SkippedRequestFields []string         `yaml:"skippedRequestFields"`
+	Overrides            []OverrideConfig `yaml:"overrides"`
+}
+
+type OverrideConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Action   string `yaml:"action"`
+	Field    string `yaml:"field"`
+	Type     string `yaml:"type"`
+}
+
+// Load adds the overrides of an overrides file, its skipped endpoints are excluded like with -exclude
+// and its skipped request fields are added to the default ones.
+func (o *Overrides) Load(fileName string) error {
+	body, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("could not read overrides: %w", err)
+	}
+
+	var file OverridesFile
+	if err := yaml.Unmarshal(body, &file); err != nil {
+		return fmt.Errorf("could not decode overrides %s: %w", fileName, err)
+	}
+
+	for i, config := range file.Overrides {
+		if config.Endpoint == "" || config.Action == "" || config.Field == "" {
+			return fmt.Errorf("override %d in %s needs an endpoint, action and field", i+1, fileName)
+		}
+
+		if config.Type == "keyed" || config.Type == "object" {
+			o.addKeyed(config.Endpoint, config.Action, config.Field, config.Type == "keyed", fileName)
+			continue
+		}
+
+		field, err := newOverrideField(config.Field, config.Type)
+		if err != nil {
+			return fmt.Errorf("override %d in %s: %w", i+1, fileName, err)
+		}
+		o.add(config.Endpoint, config.Action, config.Field, field, fileName)
+	}
+
+	if err := filters.Exclude(file.SkippedEndpoints...); err != nil {
+		return fmt.Errorf("invalid skipped endpoint in %s: %w", fileName, err)
+	}
+	skippedRequestFields = append(skippedRequestFields, file.SkippedRequestFields...)
+
+	return nil
+}
+
+func newOverrideField(name string, kind string) (Field, error) {
+	switch kind {
+	case "string":
+		return &StringField{name: name}, nil
+	case "float":
+		return &FloatField{name: name}, nil
+	case "int":
+		return &IntField{name: name}, nil
+	case "bool":
+		return &BoolField{name: name}, nil
+	case "number":
+		return &NumberField{name: name}, nil
+	case "map":
+		return NewStatementField(name, Map(String()).Interface()), nil
+	case "slice":
+		return NewStatementField(name, Index().Interface()), nil
+	case "raw":
+		return NewStatementField(name, Qual("encoding/json", "RawMessage")), nil
+	}
+
+	if i := strings.LastIndex(kind, "."); i > 0 && i < len(kind)-1 {
+		return NewStatementField(name, Qual(kind[:i], kind[i+1:])), nil
+	}
+
+	return nil, fmt.Errorf("unknown type '%s' for field %s", kind, name)
 }
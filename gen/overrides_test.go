@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeOverridesFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// overrideTypes returns the types of the overrides of the action by field, keyed for AddKeyed ones
+func overrideTypes(o *Overrides, endpoint string, action string) map[string]string {
+	types := map[string]string{}
+	for name, entry := range o.Filter(endpoint, action) {
+		switch {
+		case entry.keyed != nil && *entry.keyed:
+			types[name] = "keyed"
+		case entry.keyed != nil:
+			types[name] = "object"
+		default:
+			types[name] = sampleType(renamed(entry.field, "value"))
+		}
+	}
+	return types
+}
+
+func TestOverridesLoad(t *testing.T) {
+	previousFilters, previousSkipped := filters, skippedRequestFields
+	defer func() { filters, skippedRequestFields = previousFilters, previousSkipped }()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "overrides.yaml",
+			content: `
+skippedEndpoints: [favourites, "api/issues:re:^(bulk|set)_"]
+skippedRequestFields: [organization]
+overrides:
+  - {endpoint: measures, action: component, field: "component.measures[].value", type: float}
+  - {endpoint: measures, action: component, field: period, type: github.com/acme/sonar.Period}
+  - {endpoint: measures, action: component, field: metrics, type: raw}
+  - {endpoint: rules, action: search, field: actives, type: keyed}
+  - {endpoint: rules, action: search, field: facets, type: object}
+`,
+		},
+		{
+			name: "json",
+			file: "overrides.json",
+			content: `{
+  "skippedEndpoints": ["favourites", "api/issues:re:^(bulk|set)_"],
+  "skippedRequestFields": ["organization"],
+  "overrides": [
+    {"endpoint": "measures", "action": "component", "field": "component.measures[].value", "type": "float"},
+    {"endpoint": "measures", "action": "component", "field": "period", "type": "github.com/acme/sonar.Period"},
+    {"endpoint": "measures", "action": "component", "field": "metrics", "type": "raw"},
+    {"endpoint": "rules", "action": "search", "field": "actives", "type": "keyed"},
+    {"endpoint": "rules", "action": "search", "field": "facets", "type": "object"}
+  ]
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, skippedRequestFields = NewFilters(), []string{}
+
+			o := &Overrides{entries: map[string]map[string]map[string]*override{}}
+			if err := o.Load(writeOverridesFile(t, test.file, test.content)); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]string{"component.measures[].value": "float64", "period": "sonar.Period", "metrics": "json.RawMessage"}
+			if got := overrideTypes(o, "measures", "component"); !reflect.DeepEqual(got, want) {
+				t.Errorf("measures/component overrides are %v, want %v", got, want)
+			}
+			want = map[string]string{"actives": "keyed", "facets": "object"}
+			if got := overrideTypes(o, "rules", "search"); !reflect.DeepEqual(got, want) {
+				t.Errorf("rules/search overrides are %v, want %v", got, want)
+			}
+
+			if want := []string{"organization"}; !reflect.DeepEqual(skippedRequestFields, want) {
+				t.Errorf("skipped request fields are %v, want %v", skippedRequestFields, want)
+			}
+
+			services := filters.Apply([]Service{
+				{Path: "api/favourites", Actions: []Action{{Key: "search"}}},
+				{Path: "api/issues", Actions: []Action{{Key: "search"}, {Key: "bulk_change"}, {Key: "set_tags"}}},
+			})
+			if len(services) != 1 || len(services[0].Actions) != 1 || services[0].Actions[0].Key != "search" {
+				t.Errorf("selected %v, want only api/issues search", services)
+			}
+		})
+	}
+}
+
+func TestOverridesLoadErrors(t *testing.T) {
+	previousFilters, previousSkipped := filters, skippedRequestFields
+	defer func() { filters, skippedRequestFields = previousFilters, previousSkipped }()
+
+	tests := map[string]string{
+		"needs an endpoint, action and field": `overrides: [{endpoint: measures, action: component, type: float}]`,
+		"unknown type 'decimal'":              `overrides: [{endpoint: measures, action: component, field: value, type: decimal}]`,
+		"invalid skipped endpoint":            `skippedEndpoints: ["re:("]`,
+		"could not decode overrides":          `overrides: {endpoint: measures}`,
+	}
+
+	for want, content := range tests {
+		filters, skippedRequestFields = NewFilters(), []string{}
+
+		o := &Overrides{entries: map[string]map[string]map[string]*override{}}
+		err := o.Load(writeOverridesFile(t, "overrides.yaml", content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loading %s failed with %v, want %s", content, err, want)
+		}
+	}
+}
+
+func TestOverridesLookup(t *testing.T) {
+	o := &Overrides{entries: map[string]map[string]map[string]*override{}}
+	o.Add("measures", "component", "value", &IntField{name: "value"})
+	o.Add("measures", "component", "component.measures[].value", &FloatField{name: "value"})
+	o.Add("measures", "component", "unused", &StringField{name: "unused"})
+	actionOverrides := o.Filter("measures", "component")
+
+	if entry := actionOverrides.lookup(splitPath("component.measures[].value"), "value"); entry == nil || sampleType(entry.field) != "float64" {
+		t.Errorf("the path pattern does not take precedence over the name")
+	}
+	if entry := actionOverrides.lookup(splitPath("component.period.value"), "value"); entry == nil || sampleType(entry.field) != "int64" {
+		t.Errorf("the name does not match at any depth")
+	}
+	if entry := actionOverrides.lookup(splitPath("component.key"), "key"); entry != nil {
+		t.Errorf("key matches %s", entry.origin)
+	}
+
+	// Overrides are matched by the parsers of all services at once
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			actionOverrides.lookup(nil, "value").match()
+		}()
+	}
+	wg.Wait()
+
+	// Only the overrides of parsed actions are listed, not those of services that were skipped
+	o.Add("issues", "search", "unused", &StringField{name: "unused"})
+	parsed := []*ParsedService{
+		{service: &Service{Path: "api/measures"}, actions: []*ParsedAction{{action: Action{Key: "component"}, overrides: actionOverrides}}},
+		{service: &Service{Path: "api/rules"}, actions: []*ParsedAction{{action: Action{Key: "search"}}}},
+		nil,
+	}
+
+	unmatched := unmatchedOverrides(parsed)
+	if want := []string{"measures/component component.measures[].value (NewOverrides)", "measures/component unused (NewOverrides)"}; !reflect.DeepEqual(unmatched, want) {
+		t.Errorf("unmatched overrides are %v, want %v", unmatched, want)
+	}
+}
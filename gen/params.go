@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The Go types request fields are generated with, inferred from the spec of their param
+const (
+	paramString = "string"
+	paramBool   = "bool"
+	paramInt    = "int"
+	paramEnum   = "enum"
+	paramTime   = "time"
+	paramDate   = "date"
+)
+
+// kind infers the Go type of the param: possible values make a bool or an enum, date examples a time or a date,
+// numeric bounds or defaults an int.
+func (p *Param) kind() string {
+	if len(p.PossibleValues) > 0 {
+		if isBoolValues(p.PossibleValues) {
+			return paramBool
+		}
+		return paramEnum
+	}
+
+	if kind := p.dateKind(); kind != "" {
+		return kind
+	}
+
+	if p.MinimumValue != nil || p.MaximumValue != nil {
+		return paramInt
+	}
+	if isInt(p.DefaultValue) && (p.ExampleValue == "" || isInt(p.ExampleValue)) {
+		return paramInt
+	}
+
+	return paramString
+}
+
+// isBoolValues reports whether values are true and false, optionally with their yes and no aliases
+func isBoolValues(values []string) bool {
+	found := map[string]bool{}
+	for _, value := range values {
+		switch strings.ToLower(value) {
+		case "true", "false", "yes", "no":
+			found[strings.ToLower(value)] = true
+		default:
+			return false
+		}
+	}
+	return found["true"] && found["false"]
+}
+
+// dateKind infers a time or a date from the example of the param, e.g. "2017-10-19 or 2017-10-19T13:00:00+0200".
+// Params taking either a date or a datetime are times, which SonarQube accepts for both.
+func (p *Param) dateKind() string {
+	var kind string
+	for _, word := range strings.Fields(p.ExampleValue) {
+		switch {
+		case isTimeValue(word):
+			return paramTime
+		case isDateValue(word):
+			kind = paramDate
+		}
+	}
+
+	if strings.Contains(strings.ToLower(p.Description), "datetime") && (kind != "" || p.ExampleValue == "") {
+		return paramTime
+	}
+	return kind
+}
+
+var (
+	timeValue = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})$`)
+	dateValue = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// isTimeValue reports whether value is a SonarQube timestamp, e.g. 2017-03-01T11:39:03+0100
+func isTimeValue(value string) bool {
+	return timeValue.MatchString(value)
+}
+
+func isDateValue(value string) bool {
+	return dateValue.MatchString(value)
+}
+
+func isInt(value string) bool {
+	_, err := strconv.Atoi(value)
+	return err == nil
+}
+
+// goType returns the type of the request field, optional bools, ints, times and dates are pointers, so false and 0
+// can be sent and unset times are left out. With -pointers, optional strings and enums are pointers as well.
+// Lists are slices of their values. enum is the name of the enum type of the param, see Service.enums.
+func (p *Param) goType(enum string) *Statement {
+	var pointer *Statement
+	if p.Required {
+		pointer = Null()
+	} else {
+		pointer = Op("*")
+	}
+
+	switch p.kind() {
+	case paramBool:
+		return pointer.Bool()
+	case paramInt:
+		return pointer.Int()
+	case paramTime:
+		return pointer.Qual(qualifier(typesPackage), "Time")
+	case paramDate:
+		return pointer.Qual(qualifier(typesPackage), "Date")
+	case paramEnum:
+		if p.isList() {
+			return Index().Id(enum)
+		}
+		return p.stringPointer().Id(enum)
+	}
+	if p.isList() {
+		return Index().String()
+	}
+	return p.stringPointer().String()
+}
+
+// stringPointer makes optional strings and enums pointers with -pointers, so empty values can be sent
+func (p *Param) stringPointer() *Statement {
+	if pointers && !p.Required {
+		return Op("*")
+	}
+	return Null()
+}
+
+// isList reports whether the param takes a comma-separated list of values, only strings and enums are lists.
+func (p *Param) isList() bool {
+	switch p.kind() {
+	case paramString, paramEnum:
+		return p.MaxValuesAllowed != nil || strings.Contains(strings.ToLower(p.Description), "comma-separated")
+	}
+	return false
+}
+
+// checks returns the statements of the Validate method checking the request field of the param, see
+// RequestStructGenerator.validate. Required bools and ints always have a value, so they are not checked for it.
+func (p *Param) checks() []Code {
+	field := Id("r").Dot(strcase.ToCamel(p.Key))
+	key := Lit(p.Key)
+
+	var checks []Code
+	switch p.kind() {
+	case paramString, paramEnum:
+		if !p.isList() {
+			if p.Required {
+				checks = append(checks, Id("v").Dot("Required").Call(key, field.Clone().Op("!=").Lit("")))
+			}
+			if pointers && !p.Required {
+				if valueChecks := p.valueChecks(Op("*").Add(field.Clone())); len(valueChecks) > 0 {
+					checks = append(checks, If(field.Clone().Op("!=").Nil()).Block(valueChecks...))
+				}
+				return checks
+			}
+			return append(checks, p.valueChecks(field)...)
+		}
+
+		if p.Required {
+			checks = append(checks, Id("v").Dot("Required").Call(key, Len(field.Clone()).Op(">").Lit(0)))
+		}
+		if p.MaxValuesAllowed != nil {
+			checks = append(checks, Id("v").Dot("MaxValues").Call(key, Len(field.Clone()), Lit(*p.MaxValuesAllowed)))
+		}
+		if valueChecks := p.valueChecks(Id("value")); len(valueChecks) > 0 {
+			checks = append(checks, For(Id("_").Op(",").Id("value").Op(":=").Range().Add(field.Clone())).Block(valueChecks...))
+		}
+	case paramTime, paramDate:
+		if p.Required {
+			checks = append(checks, Id("v").Dot("Required").Call(key, Op("!").Add(field.Clone()).Dot("IsZero").Call()))
+		}
+	case paramInt:
+		value := field.Clone()
+		if !p.Required {
+			value = Op("*").Add(field.Clone())
+		}
+
+		var bounds []Code
+		if p.MinimumValue != nil {
+			bounds = append(bounds, Id("v").Dot("Min").Call(key, value.Clone(), Lit(*p.MinimumValue)))
+		}
+		if p.MaximumValue != nil {
+			bounds = append(bounds, Id("v").Dot("Max").Call(key, value.Clone(), Lit(*p.MaximumValue)))
+		}
+		if len(bounds) > 0 && !p.Required {
+			checks = append(checks, If(field.Clone().Op("!=").Nil()).Block(bounds...))
+		} else {
+			checks = append(checks, bounds...)
+		}
+	}
+
+	return checks
+}
+
+// valueChecks checks a single value of a string or enum param, either the field or a value of its list
+func (p *Param) valueChecks(value *Statement) []Code {
+	key := Lit(p.Key)
+	if p.kind() == paramEnum {
+		value = String().Call(value)
+	}
+
+	var checks []Code
+	if p.kind() == paramEnum {
+		allowed := []Code{key, value.Clone()}
+		for _, possibleValue := range p.PossibleValues {
+			allowed = append(allowed, Lit(possibleValue))
+		}
+		checks = append(checks, Id("v").Dot("OneOf").Call(allowed...))
+	}
+	if p.MinimumLength != nil {
+		checks = append(checks, Id("v").Dot("MinLength").Call(key, value.Clone(), Lit(*p.MinimumLength)))
+	}
+	if p.MaximumLength != nil {
+		checks = append(checks, Id("v").Dot("MaxLength").Call(key, value.Clone(), Lit(*p.MaximumLength)))
+	}
+	return checks
+}
+
+// Enum is a named string type with a constant for each possible value of the params it is used for.
+type Enum struct {
+	Name   string
+	Values []string
+	// The request fields of this type, e.g. SearchRequest.Severities
+	Fields []string
+}
+
+// enums returns the enum types of the type package of the service, sorted by name,
+// and the names of the types by action and param key, e.g. "search.severities".
+//
+// An enum is named after its param, in singular: the severities params give a Severity type.
+// Params with the same name but different possible values get types prefixed with their action, e.g. SearchSeverity.
+// Params of one action that still share a name, like status and statuses, get a numeric suffix, e.g. SearchStatus2.
+func (s *Service) enums() ([]*Enum, map[string]string) {
+	type enumParam struct {
+		action *Action
+		param  *Param
+	}
+
+	byName := map[string][]enumParam{}
+	for i := range s.Actions {
+		action := &s.Actions[i]
+		for j := range action.Params {
+			param := &action.Params[j]
+			if param.kind() != paramEnum || isSkippedParam(param.Key) {
+				continue
+			}
+			name := singular(strcase.ToCamel(param.Key))
+			byName[name] = append(byName[name], enumParam{action: action, param: param})
+		}
+	}
+
+	// The names are visited in order, so the suffixes do not depend on the order of the map
+	sortedNames := make([]string, 0, len(byName))
+	for name := range byName {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	enums := map[string]*Enum{}
+	names := map[string]string{}
+	for _, name := range sortedNames {
+		params := byName[name]
+		shared := true
+		for _, p := range params[1:] {
+			if strings.Join(p.param.PossibleValues, ",") != strings.Join(params[0].param.PossibleValues, ",") {
+				shared = false
+			}
+		}
+
+		for _, p := range params {
+			baseName := name
+			if !shared {
+				baseName = p.action.id() + name
+			}
+			typeName := baseName
+			for i := 2; enums[typeName] != nil && strings.Join(enums[typeName].Values, ",") != strings.Join(p.param.PossibleValues, ","); i++ {
+				typeName = fmt.Sprintf("%s%d", baseName, i)
+			}
+
+			enum, ok := enums[typeName]
+			if !ok {
+				enum = &Enum{Name: typeName, Values: p.param.PossibleValues}
+				enums[typeName] = enum
+			}
+			enum.Fields = append(enum.Fields, fmt.Sprintf("%s.%s", p.action.requestTypeName(), strcase.ToCamel(p.param.Key)))
+			names[p.action.Key+"."+p.param.Key] = typeName
+		}
+	}
+
+	sorted := make([]*Enum, 0, len(enums))
+	for _, enum := range enums {
+		sorted = append(sorted, enum)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted, names
+}
+
+// Outputs:
+//
+//	// Severity is a value of SearchRequest.Severities
+//	type Severity string
+//
+//	const (
+//		SeverityInfo  Severity = "INFO"
+//		SeverityMinor Severity = "MINOR"
+//	)
+func (e *Enum) render() *Statement {
+	statement := Commentf("%s is a value of %s", e.Name, strings.Join(e.Fields, ", ")).Line()
+	statement.Type().Id(e.Name).String().Line()
+
+	var constants []Code
+	seen := map[string]bool{}
+	for i, value := range e.Values {
+		name := e.Name + constantName(value)
+		if name == e.Name {
+			// The value has nothing to name the constant after, e.g. it is only punctuation
+			name = fmt.Sprintf("%sValue%d", e.Name, i+1)
+		}
+		if value == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		constants = append(constants, Id(name).Id(e.Name).Op("=").Lit(value))
+	}
+	statement.Const().Defs(constants...)
+
+	return statement
+}
+
+// isSkippedParam reports whether the param is left out of the request structs: a paging param or one of skippedRequestFields
+func isSkippedParam(key string) bool {
+	return key == "p" || key == "ps" || contains(key, skippedRequestFields)
+}
+
+// constantName turns a value into the suffix of its constant, e.g. CODE_SMELL into CodeSmell, empty when the value
+// has no letters or digits
+func constantName(value string) string {
+	if value == strings.ToUpper(value) {
+		value = strings.ToLower(value)
+	}
+	return strcase.ToCamel(value)
+}
+
+// singular turns a plural name into its singular, e.g. Severities into Severity and Statuses into Status.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "uses"), strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") &&
+		!strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"Severities": "Severity",
+		"Statuses":   "Status",
+		"Status":     "Status",
+		"Addresses":  "Address",
+		"Boxes":      "Box",
+		"Branches":   "Branch",
+		"Hashes":     "Hash",
+		"Issues":     "Issue",
+		"Analysis":   "Analysis",
+		"Class":      "Class",
+		"Tags":       "Tag",
+		"Key":        "Key",
+	}
+
+	for name, want := range tests {
+		if got := singular(name); got != want {
+			t.Errorf("singular(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConstantName(t *testing.T) {
+	tests := map[string]string{
+		"CODE_SMELL":    "CodeSmell",
+		"INFO":          "Info",
+		"newCodePeriod": "NewCodePeriod",
+		"in-progress":   "InProgress",
+		"-":             "",
+		"*":             "",
+	}
+
+	for value, want := range tests {
+		if got := constantName(value); got != want {
+			t.Errorf("constantName(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func enumParam(key string, values ...string) Param {
+	return Param{Key: key, PossibleValues: values}
+}
+
+func TestServiceEnums(t *testing.T) {
+	service := &Service{Path: "api/issues", Actions: []Action{
+		{Key: "search", Params: []Param{
+			enumParam("severities", "INFO", "MINOR"),
+			enumParam("status", "OPEN", "CLOSED"),
+			enumParam("statuses", "OPEN", "REOPENED"),
+		}},
+		{Key: "set_severity", Params: []Param{
+			enumParam("severity", "INFO", "MINOR"),
+		}},
+		{Key: "list", Params: []Param{
+			enumParam("status", "OPEN"),
+			enumParam("ps", "10", "20"),
+		}},
+	}}
+
+	enums, names := service.enums()
+
+	got := map[string]string{}
+	for _, enum := range enums {
+		got[enum.Name] = strings.Join(enum.Values, ",")
+	}
+	want := map[string]string{
+		"Severity":      "INFO,MINOR",
+		"SearchStatus":  "OPEN,CLOSED",
+		"SearchStatus2": "OPEN,REOPENED",
+		"ListStatus":    "OPEN",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("enums are %v, want %v", got, want)
+	}
+
+	wantNames := map[string]string{
+		"search.severities":     "Severity",
+		"search.status":         "SearchStatus",
+		"search.statuses":       "SearchStatus2",
+		"set_severity.severity": "Severity",
+		"list.status":           "ListStatus",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names are %v, want %v", names, wantNames)
+	}
+
+	// The names do not depend on the order of the maps they are collected in
+	for i := 0; i < 10; i++ {
+		if _, again := service.enums(); !reflect.DeepEqual(again, names) {
+			t.Fatalf("names are %v, then %v", names, again)
+		}
+	}
+}
+
+func TestEnumRender(t *testing.T) {
+	enum := &Enum{Name: "Qualifier", Values: []string{"TRK", "-", "", "TRK", "*"}, Fields: []string{"SearchRequest.Qualifiers"}}
+
+	code := fmt.Sprintf("%#v", enum.render())
+
+	for _, constant := range []string{"QualifierTrk ", "QualifierValue2 ", "QualifierValue5 "} {
+		if strings.Count(code, constant) != 1 {
+			t.Errorf("want one constant %s, got:\n%s", constant, code)
+		}
+	}
+	if strings.Contains(code, "Qualifier Qualifier =") {
+		t.Errorf("constant redeclares the type:\n%s", code)
+	}
+}
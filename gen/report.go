@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// Problems of a generation run, collected for -report and -strict
+var report = &Report{Entries: []ReportEntry{}}
+
+const (
+	// The service or action could not be generated at all
+	reportError = "error"
+	// A response field is missing from the generated struct
+	reportSkippedField = "skipped-field"
+	// The response example has a format that cannot be turned into a struct
+	reportUnsupportedFormat = "unsupported-format"
+	// No source has a response example for the action, the response is kept as raw JSON
+	reportMissingExample = "missing-example"
+	// The action is paged, but no All handler could be generated
+	reportNoAllHandler = "all-handler-not-generated"
+	// Generated code could not be formatted, i.e. is not valid Go
+	reportFormatFailed = "format-failed"
+	// Samples of a response field have different types, the field is widened, see FieldParser.merge
+	reportTypeConflict = "type-conflict"
+	// Response objects of the same name with shapes that are not merged into one shared type, see SharedTypes
+	reportNearMatch = "near-match"
+)
+
+var errFormatFailed = errors.New("could not format generated source")
+
+// Report lists what went wrong or was degraded during generation, per service and action.
+type Report struct {
+	Entries []ReportEntry `json:"entries"`
+	mutex   sync.Mutex
+}
+
+type ReportEntry struct {
+	Kind    string `json:"kind"`
+	Service string `json:"service,omitempty"`
+	Action  string `json:"action,omitempty"`
+	// Field is the JSON path of a response field, e.g. component.measures[].value
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail"`
+}
+
+func (e ReportEntry) Location() string {
+	location := e.Service
+	if e.Action != "" {
+		location += "/" + e.Action
+	}
+	if e.Field != "" {
+		location += " " + e.Field
+	}
+	return location
+}
+
+// add records and prints an entry, unless the same problem was reported before
+func (r *Report) add(entry ReportEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.Entries {
+		if existing == entry {
+			return
+		}
+	}
+	r.Entries = append(r.Entries, entry)
+
+	if location := entry.Location(); location != "" {
+		logf("%s: %s: %s\n", entry.Kind, location, entry.Detail)
+	} else {
+		logf("%s: %s\n", entry.Kind, entry.Detail)
+	}
+}
+
+// addError records an error of a service or action, of the kind matching the cause
+func (r *Report) addError(service string, action string, err error) {
+	kind := reportError
+	switch {
+	case errors.Is(err, errMissingExample):
+		kind = reportMissingExample
+	case errors.Is(err, errUnsupportedFormat):
+		kind = reportUnsupportedFormat
+	case errors.Is(err, errFormatFailed):
+		kind = reportFormatFailed
+	}
+	r.add(ReportEntry{Kind: kind, Service: service, Action: action, Detail: err.Error()})
+}
+
+// entries returns the entries of the service
+func (r *Report) entries(service string) []ReportEntry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var entries []ReportEntry
+	for _, entry := range r.Entries {
+		if entry.Service == service {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Failed reports whether something could not be generated at all.
+func (r *Report) Failed() bool {
+	for _, entry := range r.Entries {
+		if entry.failed() {
+			return true
+		}
+	}
+	return false
+}
+
+func (e ReportEntry) failed() bool {
+	return e.Kind == reportError || e.Kind == reportFormatFailed
+}
+
+// Degraded reports whether anything is missing from the generated code, near matches are only informational.
+func (r *Report) Degraded() bool {
+	for _, entry := range r.Entries {
+		if entry.Kind != reportNearMatch {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) sort() {
+	sort.SliceStable(r.Entries, func(i, j int) bool {
+		a, b := r.Entries[i], r.Entries[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.Field < b.Field
+	})
+}
+
+// writeSummary writes the number of problems of each kind, followed by all of them.
+func (r *Report) writeSummary(w io.Writer) error {
+	r.sort()
+
+	counts := map[string]int{}
+	for _, entry := range r.Entries {
+		counts[entry.Kind]++
+	}
+
+	fmt.Fprintf(w, "\nGeneration report: %d problems\n\n", len(r.Entries))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, kind := range []string{reportError, reportFormatFailed, reportMissingExample, reportUnsupportedFormat, reportNoAllHandler, reportSkippedField, reportTypeConflict, reportNearMatch} {
+		fmt.Fprintf(tw, "%s\t%d\n", kind, counts[kind])
+	}
+	if len(r.Entries) > 0 {
+		fmt.Fprintln(tw)
+	}
+	for _, entry := range r.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Kind, entry.Location(), entry.Detail)
+	}
+	return tw.Flush()
+}
+
+func (r *Report) writeJSON(fileName string) error {
+	r.sort()
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("could not create report: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r)
+}
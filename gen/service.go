@@ -1,10 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	. "github.com/dave/jennifer/jen"
 	"github.com/iancoleman/strcase"
-	"os"
 	"reflect"
 	"strings"
 )
@@ -15,113 +15,206 @@ type Service struct {
 	Path        string   `json:"path"`
 	Description string   `json:"description"`
 	Actions     []Action `json:"actions"`
+	// Versions lists the merged versions that have this service, see mergeSpecs.
+	Versions []string `json:"-"`
 }
 
 func (s *Service) Getter() string {
 	return strcase.ToCamel(s.endpoint())
 }
 
+// Compatibility describes which of the merged versions have this service.
+func (s *Service) Compatibility() string {
+	return compatibility(s.Versions)
+}
+
 func (s *Service) endpoint() string {
 	path := strings.Split(s.Path, "/")
 	return path[len(path)-1]
 }
 
-func (s *Service) process(output string) error {
-	overrides := NewOverrides()
+// ParsedService is a service with the response fields of its actions. It is rendered once all services are parsed,
+// so the types shared between services are known, see SharedTypes.
+type ParsedService struct {
+	service *Service
+	actions []*ParsedAction
+}
 
-	endpoint := s.endpoint()
-	if contains(endpoint, skippedEndpoints) {
-		fmt.Printf("Skipping endpoint '%s'\n", endpoint)
-		return nil
+// ParsedAction is an action with the response fields parsed from its response example
+type ParsedAction struct {
+	action Action
+	// The response and, for paged actions, the collection of all pages
+	response    Field
+	responseAll Field
+	origin      string
+	// The overrides the response was parsed with, nil when no response example was parsed
+	overrides ActionOverrides
+}
+
+// parse parses the response examples of the actions. A failing action is reported and left out,
+// the other actions of the service are still generated.
+func (s *Service) parse(source Source) *ParsedService {
+	parsed := &ParsedService{service: s}
+	for _, action := range s.Actions {
+		logf("Processing '%s' - '%s'\n", s.endpoint(), action.Key)
+
+		parsedAction, err := s.parseAction(action, source)
+		if err != nil {
+			report.addError(s.Path, action.Key, err)
+			continue
+		}
+		parsed.actions = append(parsed.actions, parsedAction)
+	}
+	return parsed
+}
+
+func (s *Service) parseAction(action Action, source Source) (*ParsedAction, error) {
+	parsed := &ParsedAction{action: action, response: &EmptyField{}, responseAll: &EmptyField{}}
+	if !action.HasResponseExample {
+		return parsed, nil
+	}
+
+	example, origin, err := action.fetchExample(source, s.Path)
+	if errors.Is(err, errMissingExample) || errors.Is(err, errUnsupportedFormat) {
+		// The request does not depend on the response, the action is still generated with the raw response
+		report.addError(s.Path, action.Key, fmt.Errorf("%w, the response is generated as json.RawMessage", err))
+		parsed.response = NewStatementField(action.responseTypeName(), Op("=").Qual("encoding/json", "RawMessage"))
+		return parsed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	parsed.origin = origin
+
+	parsed.overrides = overrides.Filter(s.endpoint(), action.Key)
+	parser := NewFieldParser(s, &action, parsed.overrides)
+	responseFieldsGenerator := NewResponseFieldsGenerator(parser)
+
+	parsed.response, err = responseFieldsGenerator.generate(action.responseTypeName(), example)
+	if err != nil {
+		return nil, fmt.Errorf("could not collect response fields: %+v", err)
+	}
+
+	if action.hasPaging() {
+		object, ok := example.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("paged response example is not an object")
+		}
+		parsed.responseAll, err = responseFieldsGenerator.generatedWithoutPaging(action.responseAllTypeName(), object)
+		if err != nil {
+			return nil, fmt.Errorf("could not extract collection field: %+v", err)
+		}
 	}
 
+	return parsed, nil
+}
+
+func (p *ParsedService) render(output string) error {
+	s := p.service
+	endpoint := s.endpoint()
+
 	typesFile := NewFile(endpoint)
-	typesFile.Commentf("// AUTOMATICALLY GENERATED, DO NOT EDIT BY HAND!\n")
+	typesFile.Commentf("%s\n", generatedMarker)
 
 	serviceFile := NewFile(packageName)
 	serviceFile.ImportName(qualifier(endpoint), endpoint)
 	serviceFile.ImportName(qualifier("paging"), "paging")
-	serviceFile.Commentf("// AUTOMATICALLY GENERATED, DO NOT EDIT BY HAND!\n")
+	serviceFile.Commentf("%s\n", generatedMarker)
 
-	serviceType := Type().Id(s.Getter()).Id("service")
-	serviceFile.Add(serviceType)
+	if serviceTemplate != nil {
+		serviceCode, err := renderCode(serviceTemplate, s.templateData())
+		if err != nil {
+			return err
+		}
+		serviceFile.Add(serviceCode)
+	} else {
+		serviceType := Type().Id(s.Getter()).Id("service")
+		serviceFile.Add(serviceType)
+	}
 
-	for _, action := range s.Actions {
-		if s.Path == "api/sources" && action.Key == "index" {
+	enums, _ := s.enums()
+	for _, enum := range enums {
+		typesFile.Add(enum.render())
+	}
+
+	// The nested types of all actions are declared in the same package, their names must not collide
+	nested := NewNestedTypes(s)
+	for _, parsed := range p.actions {
+		types, functions, err := s.renderAction(parsed, nested)
+		if err != nil {
+			report.addError(s.Path, parsed.action.Key, err)
 			continue
 		}
-		fmt.Printf("Processing '%s' - '%s'\n", s.endpoint(), action.Key)
-
-		requestStructGenerator := NewRequestStructGenerator(s, &action)
-		requestStruct := requestStructGenerator.generate()
-		typesFile.Add(requestStruct)
-
-		var responseField Field = &EmptyField{}
-		var responseFieldWithoutPaging Field = &EmptyField{}
-		if action.HasResponseExample {
-			example, err := action.fetchExample(endpoint)
-			if err != nil {
-				return fmt.Errorf("could not fetch example: %+v", err)
-			}
-
-			parser := NewFieldParser(s, &action, overrides.Filter(s.endpoint(), action.Key))
-			responseFieldsGenerator := NewResponseFieldsGenerator(parser)
-
-			responseField, err = responseFieldsGenerator.generate(action.responseTypeName(), example)
-			if err != nil {
-				return fmt.Errorf("could not collect response fields: %+v", err)
-			}
-
-			if action.hasPaging() {
-				responseFieldWithoutPaging, err = responseFieldsGenerator.generatedWithoutPaging(action.responseAllTypeName(), example.(map[string]interface{}))
-				if err != nil {
-					return fmt.Errorf("could not extract collection field: %+v", err)
-				}
-			}
+		for _, code := range types {
+			typesFile.Add(code)
+		}
+		for _, code := range functions {
+			serviceFile.Add(code)
 		}
+	}
 
-		responseStruct := action.responseStruct(responseField)
-		typesFile.Add(responseStruct)
+	fileNames := s.fileNames(output)
+	if err := saveFile(typesFile, fileNames[0]); err != nil {
+		return fmt.Errorf("could not save generated source file for types: %w", err)
+	}
 
-		if action.hasPaging() {
-			pagingFunc := action.responseStructPagingFunc(responseField)
-			typesFile.Add(pagingFunc)
-		}
+	if err := saveFile(serviceFile, fileNames[1]); err != nil {
+		return fmt.Errorf("could not save generated source file for service: %w", err)
+	}
 
-		responseAllStruct := action.responseAllStruct(responseFieldWithoutPaging)
-		typesFile.Add(responseAllStruct)
+	return nil
+}
 
-		// Service file
-		if action.Post {
-			postActionOutput := s.postServiceFunc(action, endpoint)
-			serviceFile.Add(postActionOutput)
-		} else {
-			getActionOutput := s.getServiceFunc(action, endpoint)
-			serviceFile.Add(getActionOutput)
-		}
+// renderAction returns the request and response types and the service functions of an action,
+// the objects nested in its response are hoisted with the nested types of the service.
+func (s *Service) renderAction(parsed *ParsedAction, nested *NestedTypes) ([]Code, []Code, error) {
+	action := parsed.action
+	endpoint := s.endpoint()
+	var types, functions []Code
 
-		if action.hasPaging() {
-			getPagedActionOutput := s.getAllServiceFunc(action, endpoint, responseFieldWithoutPaging)
-			serviceFile.Add(getPagedActionOutput)
-		}
+	requestStructGenerator := NewRequestStructGenerator(s, &action)
+	requestStruct := requestStructGenerator.generate()
+	types = append(types, requestStruct)
+	types = append(types, requestStructGenerator.validate())
+
+	// The nested types are hoisted before the response types are rendered, and declared after them.
+	// The collection of a paged response has the same nested types as the response.
+	responseTypes := nested.hoist(parsed.response, action.responseTypeName())
+	responseAllTypes := nested.hoist(parsed.responseAll, action.responseTypeName())
+
+	responseStruct := action.responseStruct(parsed.response, parsed.origin)
+	types = append(types, responseStruct)
+	types = append(types, responseTypes...)
+
+	if action.hasPaging() {
+		pagingFunc := action.responseStructPagingFunc(parsed.response)
+		types = append(types, pagingFunc)
 	}
 
-	dir := fmt.Sprintf("%s/%s", output, endpoint)
-	_ = os.Mkdir(dir, 0755)
+	responseAllStruct := action.responseAllStruct(parsed.responseAll, parsed.origin)
+	types = append(types, responseAllStruct)
+	types = append(types, responseAllTypes...)
 
-	typesFileName := fmt.Sprintf("%s/%s/%s_gen.go", output, endpoint, endpoint)
-	err := typesFile.Save(typesFileName)
-	if err != nil {
-		return fmt.Errorf("could not save generated source file for types: %+v\n", err)
+	// Service file
+	if actionTemplate != nil {
+		actionCode, err := renderCode(actionTemplate, s.actionTemplateData(&action))
+		if err != nil {
+			return nil, nil, err
+		}
+		functions = append(functions, actionCode)
+	} else if action.Post {
+		postActionOutput := s.postServiceFunc(action, endpoint)
+		functions = append(functions, postActionOutput)
+	} else {
+		getActionOutput := s.getServiceFunc(action, endpoint)
+		functions = append(functions, getActionOutput)
 	}
 
-	serviceFileName := fmt.Sprintf("%s/%s_gen.go", output, endpoint)
-	err = serviceFile.Save(serviceFileName)
-	if err != nil {
-		return fmt.Errorf("could not save generated source file for service: %+v\n", err)
+	if action.hasPaging() {
+		getPagedActionOutput := s.getAllServiceFunc(action, endpoint, parsed.responseAll)
+		functions = append(functions, getPagedActionOutput)
 	}
 
-	return nil
+	return types, functions, nil
 }
 
 func (s *Service) postServiceFunc(action Action, endpoint string) *Statement {
@@ -133,6 +226,9 @@ func (s *Service) postServiceFunc(action Action, endpoint string) *Statement {
 	if action.DeprecatedSince != "" {
 		comment += fmt.Sprintf("\n// Deprecated since %s", action.DeprecatedSince)
 	}
+	if compat := compatibility(action.Versions); compat != "" {
+		comment += fmt.Sprintf("\n// %s", compat)
+	}
 	if action.ChangeLog != nil && len(action.ChangeLog) > 0 {
 		comment += "\n// Changelog:\n//"
 		for _, change := range action.ChangeLog {
@@ -163,6 +259,11 @@ func (s *Service) postServiceFunc(action Action, endpoint string) *Statement {
 
 	// function body
 	statement.Block(
+		// if err := r.Validate(); err != nil {
+		//	return nil, nil, err
+		// }
+		ifInvalidReturn(action.HasResponseExample),
+		Line(),
 		// u := fmt.Sprintf("%s/<key>", s.path)
 		Id("u").Op(":=").Qual("fmt", "Sprintf").Call(
 			Lit(fmt.Sprintf("%%s/%s", action.Key)),
@@ -243,6 +344,9 @@ func (s *Service) getServiceFunc(action Action, endpoint string) *Statement {
 	if action.DeprecatedSince != "" {
 		comment += fmt.Sprintf("\n// Deprecated since %s", action.DeprecatedSince)
 	}
+	if compat := compatibility(action.Versions); compat != "" {
+		comment += fmt.Sprintf("\n// %s", compat)
+	}
 	if action.ChangeLog != nil && len(action.ChangeLog) > 0 {
 		comment += "\n// Changelog:\n//"
 		for _, change := range action.ChangeLog {
@@ -274,6 +378,11 @@ func (s *Service) getServiceFunc(action Action, endpoint string) *Statement {
 
 	// function body
 	statement.Block(
+		// if err := r.Validate(); err != nil {
+		//	return nil, nil, err
+		// }
+		ifInvalidReturn(action.HasResponseExample),
+		Line(),
 		// u := fmt.Sprintf("%s/<key>", s.path)
 		Id("u").Op(":=").Qual("fmt", "Sprintf").Call(
 			Lit(fmt.Sprintf("%%s/%s", action.Key)),
@@ -328,7 +437,7 @@ func (s *Service) getAllServiceFunc(action Action, endpoint string, field Field)
 	// Just to be safe, check field type
 	mapField, ok := field.(*MapField)
 	if !ok {
-		fmt.Printf("Not generating 'All' handler for %s/%s, only map fields supported, got: %+v\n", s.endpoint(), action.Key, reflect.TypeOf(field))
+		report.add(ReportEntry{Kind: reportNoAllHandler, Service: s.Path, Action: action.Key, Detail: fmt.Sprintf("only map fields supported, got: %+v", reflect.TypeOf(field))})
 		return Empty()
 	}
 
@@ -344,7 +453,7 @@ func (s *Service) getAllServiceFunc(action Action, endpoint string, field Field)
 				Id("res").Dot(accessor).Op("..."),
 			)
 		default:
-			fmt.Printf("Skipping field '%s' for %s.%s, only slices are supported.\n", mapField.fields[i].Name(), action.Key, action.responseAllTypeName())
+			report.add(ReportEntry{Kind: reportSkippedField, Service: s.Path, Action: action.Key, Field: mapField.fields[i].Name(), Detail: fmt.Sprintf("not collected in %s, only slices are supported", action.responseAllTypeName())})
 		}
 	}
 
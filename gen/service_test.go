@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestServiceParseWithoutExample(t *testing.T) {
+	report = &Report{Entries: []ReportEntry{}}
+	var log bytes.Buffer
+	previous := logOutput
+	logOutput = &log
+	defer func() { logOutput = previous }()
+
+	service := &Service{Path: "api/issues", Actions: []Action{
+		{Key: "search", HasResponseExample: true},
+		{Key: "show", HasResponseExample: true},
+	}}
+	parsed := service.parse(exampleSource{"api/issues/show": `{"key": "a"}`})
+
+	var actions []string
+	for _, action := range parsed.actions {
+		actions = append(actions, action.action.Key)
+	}
+	if want := []string{"search", "show"}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("parsed actions are %v, want %v", actions, want)
+	}
+	// The response is declared as an alias, type SearchResponse = json.RawMessage
+	if got := fmt.Sprintf("%#v", parsed.actions[0].response.Render(false)); got != "SearchResponse = json.RawMessage" {
+		t.Errorf("response of search is %s, want an alias of json.RawMessage", got)
+	}
+	if len(report.Entries) != 1 || report.Entries[0].Kind != reportMissingExample || report.Failed() {
+		t.Errorf("report entries are %v, want a single missing example", report.Entries)
+	}
+}
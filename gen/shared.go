@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SharedTypes lifts the response objects found with the same shape in several services into the shared types
+// package, see -shared-types. Objects are grouped by their name, e.g. the component and components[] fields give
+// the Component type. An object whose fields are a subset of a single other shape of its group uses that shape.
+// Shapes of the same name that cannot be merged are reported as near matches.
+type SharedTypes struct {
+	// Type names already declared in the types package
+	used         map[string]bool
+	declarations []Code
+}
+
+// shapeOccurrence is a nested object of a response, which is replaced when its shape is shared
+type shapeOccurrence struct {
+	service string
+	name    string
+	height  int
+	field   *MapField
+	replace func(Field)
+}
+
+// shape is a distinct object shape of a group, with where it occurs
+type shape struct {
+	signature   string
+	fields      map[string]string
+	field       *MapField
+	occurrences []*shapeOccurrence
+}
+
+func (s *shape) services() []string {
+	return occurrenceServices(s.occurrences)
+}
+
+func occurrenceServices(occurrences []*shapeOccurrence) []string {
+	seen := map[string]bool{}
+	var services []string
+	for _, occurrence := range occurrences {
+		if !seen[occurrence.service] {
+			seen[occurrence.service] = true
+			services = append(services, occurrence.service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// NewSharedTypes starts with the names declared by the types template, given as its rendered source
+func NewSharedTypes(typesSource []byte) (*SharedTypes, error) {
+	used, err := declaredNames(typesSource)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedTypes{used: used}, nil
+}
+
+func declaredNames(source []byte) (map[string]bool, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), typesFileName(), source, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the types package: %w", err)
+	}
+
+	names := map[string]bool{}
+	for name, object := range file.Scope.Objects {
+		if object.Kind != ast.Bad {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// dedupe replaces the shared objects in the responses of the services by references to the shared types,
+// from the innermost objects out, so objects containing shared objects can be shared as well.
+func (t *SharedTypes) dedupe(services []*ParsedService) {
+	var occurrences []*shapeOccurrence
+	for _, service := range services {
+		for _, action := range service.actions {
+			for _, response := range []Field{action.response, action.responseAll} {
+				collectShapes(service.service.Path, response, "", nil, &occurrences)
+			}
+		}
+	}
+
+	byHeight := map[int][]*shapeOccurrence{}
+	maxHeight := 0
+	for _, occurrence := range occurrences {
+		byHeight[occurrence.height] = append(byHeight[occurrence.height], occurrence)
+		if occurrence.height > maxHeight {
+			maxHeight = occurrence.height
+		}
+	}
+
+	for height := 0; height <= maxHeight; height++ {
+		groups := map[string][]*shapeOccurrence{}
+		for _, occurrence := range byHeight[height] {
+			groups[occurrence.name] = append(groups[occurrence.name], occurrence)
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			t.dedupeGroup(name, groups[name])
+		}
+	}
+}
+
+// collectShapes records the objects nested in field, the response itself is not shared.
+// set replaces field in its parent, name is the name of its shared type.
+func collectShapes(service string, field Field, name string, set func(Field), occurrences *[]*shapeOccurrence) int {
+	switch f := field.(type) {
+	case *MapField:
+		height := 0
+		for i, child := range f.fields {
+			i := i
+			childName := strcase.ToCamel(child.Name())
+			if _, ok := child.(*MapField); !ok {
+				childName = singular(childName)
+			}
+			if h := collectShapes(service, child, childName, func(replacement Field) { f.fields[i] = replacement }, occurrences); h+1 > height {
+				height = h + 1
+			}
+		}
+		if set != nil && name != "" {
+			*occurrences = append(*occurrences, &shapeOccurrence{service: service, name: name, height: height, field: f, replace: set})
+		}
+		return height
+	case *SliceField:
+		return collectShapes(service, f.elem, name, func(replacement Field) { f.elem = replacement }, occurrences)
+	case *KeyedMapField:
+		return collectShapes(service, f.elem, name, func(replacement Field) { f.elem = replacement }, occurrences)
+	}
+	return 0
+}
+
+func (t *SharedTypes) dedupeGroup(name string, occurrences []*shapeOccurrence) {
+	if len(occurrenceServices(occurrences)) < 2 {
+		return
+	}
+
+	// The distinct shapes, in a stable order
+	bySignature := map[string]*shape{}
+	var shapes []*shape
+	for _, occurrence := range occurrences {
+		fields := shapeFields(occurrence.field)
+		signature := shapeSignature(fields)
+		s, ok := bySignature[signature]
+		if !ok {
+			s = &shape{signature: signature, fields: fields, field: occurrence.field}
+			bySignature[signature] = s
+			shapes = append(shapes, s)
+		}
+		s.occurrences = append(s.occurrences, occurrence)
+	}
+	sort.SliceStable(shapes, func(i, j int) bool {
+		return shapes[i].signature < shapes[j].signature
+	})
+
+	var maximal []*shape
+	for _, s := range shapes {
+		if !hasSuperset(s, shapes) {
+			maximal = append(maximal, s)
+		}
+	}
+
+	// Each maximal shape collects the shapes it is the only superset of
+	clusters := map[*shape][]*shape{}
+	for _, s := range shapes {
+		var supersets []*shape
+		for _, m := range maximal {
+			if isSubset(s.fields, m.fields) {
+				supersets = append(supersets, m)
+			}
+		}
+		if len(supersets) == 1 {
+			clusters[supersets[0]] = append(clusters[supersets[0]], s)
+		} else {
+			report.add(ReportEntry{Kind: reportNearMatch, Field: name, Detail: fmt.Sprintf("the shape of %s fits %d different shapes, it is not shared", strings.Join(s.services(), ", "), len(supersets))})
+		}
+	}
+
+	// Clusters found in several services are shared, the others stay in their service
+	var shared []*shape
+	for _, m := range maximal {
+		var cluster []*shapeOccurrence
+		for _, s := range clusters[m] {
+			cluster = append(cluster, s.occurrences...)
+		}
+		if len(occurrenceServices(cluster)) >= 2 {
+			t.declare(name, m, cluster)
+			shared = append(shared, m)
+		}
+	}
+
+	// The other shapes are compared with the first shared one, or the first one when none is shared
+	reference := maximal[0]
+	if len(shared) > 0 {
+		reference = shared[0]
+	}
+	for _, m := range maximal {
+		if m != reference && isNearMatch(reference.fields, m.fields) {
+			report.add(ReportEntry{Kind: reportNearMatch, Field: name, Detail: fmt.Sprintf("the shape of %s is not merged with the one of %s, they differ in %s", strings.Join(m.services(), ", "), strings.Join(reference.services(), ", "), strings.Join(shapeDifferences(reference.fields, m.fields), ", "))})
+		}
+	}
+}
+
+// declare adds a shared type for the shape, and replaces the occurrences by references to it
+func (t *SharedTypes) declare(name string, s *shape, occurrences []*shapeOccurrence) {
+	typeName := name
+	for i := 2; t.used[typeName]; i++ {
+		typeName = fmt.Sprintf("%s%d", name, i)
+	}
+	t.used[typeName] = true
+
+	// The objects nested in the shared type are named after it, unless shared themselves
+	nested := newNestedTypes(t.used).hoist(s.field, typeName)
+
+	statement := Commentf("%s is shared by the responses of %s", typeName, strings.Join(occurrenceServices(occurrences), ", "))
+	statement.Line()
+	statement.Type().Add((&MapField{name: typeName, fields: s.field.fields}).Render(false))
+	t.declarations = append(t.declarations, statement)
+	t.declarations = append(t.declarations, nested...)
+
+	for _, occurrence := range occurrences {
+		occurrence.replace(NewStatementField(occurrence.field.Name(), Qual(qualifier(typesPackage), typeName)))
+	}
+}
+
+// shapeFields renders each field of the object, with its name, type and tag
+func shapeFields(m *MapField) map[string]string {
+	fields := make(map[string]string, len(m.fields))
+	for _, field := range m.fields {
+		fields[field.Name()] = fmt.Sprintf("%#v", field.Render(true))
+	}
+	return fields
+}
+
+func shapeSignature(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(fields[name])
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func hasSuperset(s *shape, shapes []*shape) bool {
+	for _, other := range shapes {
+		if other != s && isSubset(s.fields, other.fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubset reports whether every field of a is in b with the same type
+func isSubset(a map[string]string, b map[string]string) bool {
+	for name, field := range a {
+		if b[name] != field {
+			return false
+		}
+	}
+	return true
+}
+
+// isNearMatch reports whether at least half of the fields of the smaller shape are in the other one with the same type
+func isNearMatch(a map[string]string, b map[string]string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for name, field := range a {
+		if b[name] == field {
+			common++
+		}
+	}
+	return common > 0 && 2*common >= len(a)
+}
+
+// shapeDifferences lists the fields that are missing from either shape or have different types
+func shapeDifferences(a map[string]string, b map[string]string) []string {
+	var differences []string
+	for name, field := range a {
+		if other, ok := b[name]; !ok || other != field {
+			differences = append(differences, name)
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			differences = append(differences, name)
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
+// save writes the shared types into the types package, or removes the file of a previous run when there are none
+func (t *SharedTypes) save(output string) error {
+	fileName := fmt.Sprintf("%s/%s", output, sharedTypesFileName())
+	if len(t.declarations) == 0 {
+		return removeStaleShared(fileName)
+	}
+
+	file := NewFilePathName(qualifier(typesPackage), typesPackage)
+	file.Commentf("%s\n", generatedMarker)
+	for _, declaration := range t.declarations {
+		file.Add(declaration)
+	}
+	return saveFile(file, fileName)
+}
+
+func sharedTypesFileName() string {
+	return fmt.Sprintf("%s/shared_gen.go", typesPackage)
+}
+
+// removeStaleShared removes the shared types of a previous run, if any
+func removeStaleShared(fileName string) error {
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return nil
+	}
+	return removeGenerated(fileName)
+}
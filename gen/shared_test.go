@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parsedService parses the response example of a single action of the service
+func parsedService(t *testing.T, path string, example string) *ParsedService {
+	t.Helper()
+
+	service := &Service{Path: path, Actions: []Action{{Key: "show", HasResponseExample: true}}}
+	parser := &FieldParser{service: service, action: &service.Actions[0], overrides: ActionOverrides{}}
+	response := parser.NewMapField(nil, "ShowResponse", decodeSample(t, example).(map[string]interface{}))
+
+	return &ParsedService{service: service, actions: []*ParsedAction{{action: service.Actions[0], response: response, responseAll: &EmptyField{}}}}
+}
+
+func nearMatches() []string {
+	var details []string
+	for _, entry := range report.Entries {
+		if entry.Kind == reportNearMatch {
+			details = append(details, entry.Field+": "+entry.Detail)
+		}
+	}
+	sort.Strings(details)
+	return details
+}
+
+func TestSharedTypesDedupe(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples map[string]string
+		// The types of the component field by service
+		want        map[string]string
+		declared    []string
+		nearMatches []string
+	}{
+		{
+			name: "same shape",
+			examples: map[string]string{
+				"api/a": `{"component": {"key": "a", "name": "A"}}`,
+				"api/b": `{"component": {"key": "b", "name": "B"}}`,
+			},
+			want:     map[string]string{"api/a": "types.Component", "api/b": "types.Component"},
+			declared: []string{"Component"},
+		},
+		{
+			name: "subset of a single shape",
+			examples: map[string]string{
+				"api/a": `{"component": {"key": "a"}}`,
+				"api/b": `{"component": {"key": "b", "name": "B"}}`,
+			},
+			want:     map[string]string{"api/a": "types.Component", "api/b": "types.Component"},
+			declared: []string{"Component"},
+		},
+		{
+			name: "shape in a single service",
+			examples: map[string]string{
+				"api/a": `{"component": {"key": "a"}}`,
+				"api/b": `{"project": {"key": "b"}}`,
+			},
+			want: map[string]string{"api/a": "object", "api/b": ""},
+		},
+		{
+			name: "near match",
+			examples: map[string]string{
+				"api/a": `{"component": {"key": "a", "name": "A", "path": "src"}}`,
+				"api/b": `{"component": {"key": "b", "name": "B", "qualifier": "TRK"}}`,
+			},
+			want:        map[string]string{"api/a": "object", "api/b": "object"},
+			nearMatches: []string{"Component: the shape of api/b is not merged with the one of api/a, they differ in path, qualifier"},
+		},
+		{
+			name: "subset of several shapes",
+			examples: map[string]string{
+				"api/a": `{"component": {"key": "a"}}`,
+				"api/b": `{"component": {"key": "b", "name": "B"}}`,
+				"api/c": `{"component": {"key": "c", "path": "src"}}`,
+			},
+			want: map[string]string{"api/a": "object", "api/b": "object", "api/c": "object"},
+			nearMatches: []string{
+				"Component: the shape of api/a fits 2 different shapes, it is not shared",
+				"Component: the shape of api/c is not merged with the one of api/b, they differ in name, path",
+			},
+		},
+		{
+			name: "name taken by the types package",
+			examples: map[string]string{
+				"api/a": `{"time": {"value": 1}}`,
+				"api/b": `{"time": {"value": 2}}`,
+			},
+			declared: []string{"Time2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report = &Report{Entries: []ReportEntry{}}
+
+			var services []*ParsedService
+			for path, example := range test.examples {
+				services = append(services, parsedService(t, path, example))
+			}
+			sort.Slice(services, func(i, j int) bool {
+				return services[i].service.Path < services[j].service.Path
+			})
+
+			shared, err := NewSharedTypes([]byte("package types\n\ntype Time struct{}\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			shared.dedupe(services)
+
+			if test.want != nil {
+				got := map[string]string{}
+				for _, service := range services {
+					got[service.service.Path] = fieldType(service.actions[0].response.(*MapField), "component")
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("components are %v, want %v", got, test.want)
+				}
+			}
+
+			declared := declaredTypes(t, shared.declarations)
+			var names []string
+			for name := range declared {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.declared) {
+				t.Errorf("declared %v, want %v", names, test.declared)
+			}
+			for _, name := range names {
+				if !strings.Contains(declared[name], "api/a, api/b") {
+					t.Errorf("declaration of %s does not list its services:\n%s", name, declared[name])
+				}
+			}
+
+			if got := nearMatches(); !reflect.DeepEqual(got, test.nearMatches) {
+				t.Errorf("near matches are %q, want %q", got, test.nearMatches)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A snapshot captures everything the generator fetches from a server, so generation can be replayed offline.
+// Every server version gets its own directory:
+//
+//	<root>/<version>/snapshot.json                          metadata, see SnapshotInfo
+//	<root>/<version>/webservices.json                       body of /api/webservices/list
+//	<root>/<version>/examples/<controller>/<action>.json    body of /api/webservices/response_example
+//	<root>/<version>/examples/<controller>/<action>.origin  server the example was taken from
+const (
+	snapshotInfoFileName        = "snapshot.json"
+	snapshotWebservicesFileName = "webservices.json"
+	snapshotExamplesDir         = "examples"
+)
+
+type SnapshotInfo struct {
+	Version  string `json:"version"`
+	Host     string `json:"host"`
+	Internal bool   `json:"internal"`
+}
+
+// snapshotSource replays a snapshot from disk.
+type snapshotSource struct {
+	dir  string
+	info SnapshotInfo
+}
+
+func openSnapshot(dir string) (*snapshotSource, error) {
+	body, err := ioutil.ReadFile(filepath.Join(dir, snapshotInfoFileName))
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot metadata: %w", err)
+	}
+
+	s := &snapshotSource{dir: dir}
+	if err := json.Unmarshal(body, &s.info); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot metadata: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *snapshotSource) Webservices() ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.dir, snapshotWebservicesFileName))
+}
+
+// ResponseExample returns the recorded example, with the server it was originally taken from as origin.
+// Replaying a snapshot therefore gives the same output as generating against the server did.
+func (s *snapshotSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	body, origin, err := readExample(snapshotExamplePath(s.dir, controller, action))
+	if os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("no response example for %s/%s in snapshot %s", controller, action, s.dir)
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if origin == "" {
+		origin = s.info.Host
+	}
+
+	return body, origin, nil
+}
+
+// snapshotExamplePath returns the path of the example files of an action, without extension.
+func snapshotExamplePath(dir string, controller string, action string) string {
+	return filepath.Join(dir, snapshotExamplesDir, filepath.FromSlash(controller), action)
+}
+
+// readExample reads the example files at path, the origin is empty when it was not recorded.
+func readExample(path string) ([]byte, string, error) {
+	body, err := ioutil.ReadFile(path + ".json")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var origin string
+	if recorded, err := ioutil.ReadFile(path + ".origin"); err == nil {
+		origin = strings.TrimSpace(string(recorded))
+	}
+
+	return body, origin, nil
+}
+
+// writeExample writes the example files at path.
+func writeExample(path string, body []byte, origin string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".origin", []byte(origin+"\n"), 0644); err != nil {
+		return err
+	}
+	return writeFileAtomic(path+".json", body)
+}
+
+// recordSnapshot fetches the webservices list from source and every response example from examples,
+// and stores them in <root>/<server version>. It returns the directory of the snapshot.
+func recordSnapshot(source *httpSource, examples Source, root string) (string, error) {
+	version, err := source.Version()
+	if err != nil {
+		return "", fmt.Errorf("could not determine server version: %w", err)
+	}
+
+	dir := filepath.Join(root, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create snapshot directory: %w", err)
+	}
+
+	webservices, err := source.Webservices()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch api definitions: %w", err)
+	}
+
+	var api Api
+	if err := json.Unmarshal(webservices, &api); err != nil {
+		return "", fmt.Errorf("could not decode api definitions: %w", err)
+	}
+
+	for _, service := range api.Services {
+		for _, action := range service.Actions {
+			if !action.HasResponseExample {
+				continue
+			}
+
+			logf("Recording '%s' - '%s'\n", service.Path, action.Key)
+			example, origin, err := examples.ResponseExample(service.Path, action.Key)
+			if err != nil {
+				return "", fmt.Errorf("could not fetch example for %s/%s: %w", service.Path, action.Key, err)
+			}
+
+			if err := writeExample(snapshotExamplePath(dir, service.Path, action.Key), example, origin); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, snapshotWebservicesFileName), webservices, 0644); err != nil {
+		return "", err
+	}
+
+	info, err := json.MarshalIndent(SnapshotInfo{Version: version, Host: source.host, Internal: source.internal}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, snapshotInfoFileName), info, 0644); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
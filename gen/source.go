@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const serverVersionUrl = "/api/server/version"
+
+// Source provides the webservices definition and the response examples the generator works from.
+type Source interface {
+	// Webservices returns the raw body of /api/webservices/list.
+	Webservices() ([]byte, error)
+	// ResponseExample returns the raw body of /api/webservices/response_example for the given controller and action,
+	// together with the origin of the example, i.e. the server it was taken from.
+	ResponseExample(controller string, action string) ([]byte, string, error)
+}
+
+// httpSource reads everything from a live SonarQube server, through the shared fetcher.
+type httpSource struct {
+	host     string
+	auth     string
+	internal bool
+}
+
+func newHTTPSource(host string, auth string, internal bool) *httpSource {
+	return &httpSource{
+		host:     host,
+		auth:     auth,
+		internal: internal,
+	}
+}
+
+// get sends the request, with the credentials only when it goes to the host of the source,
+// so they are never sent to another server, e.g. the public one examples are taken from.
+func (s *httpSource) get(req *http.Request) ([]byte, error) {
+	if s.auth != "" && s.owns(req.URL) {
+		req.Header.Add("Authorization", s.auth)
+	}
+
+	return fetcher.Get(req)
+}
+
+// owns reports whether the url points to the host of the source
+func (s *httpSource) owns(u *url.URL) bool {
+	hostUrl, err := url.Parse(s.host)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, hostUrl.Scheme) && strings.EqualFold(u.Host, hostUrl.Host)
+}
+
+func (s *httpSource) Webservices() ([]byte, error) {
+	apiUrl := s.host + webservicesUrl
+	if s.internal {
+		apiUrl += includeInternalUrl
+	}
+
+	req, err := http.NewRequest("GET", apiUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return s.get(req)
+}
+
+func (s *httpSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	req, err := newRequest(s.host, ResponseExampleRequest{Controller: controller, Action: action})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create request: %+v", err)
+	}
+
+	body, err := s.get(req)
+	return body, s.host, err
+}
+
+// Version returns the version reported by the server, e.g. 9.9.0.65466.
+func (s *httpSource) Version() (string, error) {
+	req, err := http.NewRequest("GET", s.host+serverVersionUrl, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	body, err := s.get(req)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// fallbackSource reads the webservices list from the first source, and takes every response example
+// from the first source that has it.
+type fallbackSource struct {
+	sources []Source
+}
+
+func newFallbackSource(sources ...Source) *fallbackSource {
+	return &fallbackSource{sources: sources}
+}
+
+func (s *fallbackSource) Webservices() ([]byte, error) {
+	return s.sources[0].Webservices()
+}
+
+func (s *fallbackSource) ResponseExample(controller string, action string) ([]byte, string, error) {
+	errs := make([]string, 0, len(s.sources))
+	for _, source := range s.sources {
+		body, origin, err := source.ResponseExample(controller, action)
+		if err == nil {
+			return body, origin, nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return nil, "", fmt.Errorf("no source has a response example for %s/%s: %s", controller, action, strings.Join(errs, "; "))
+}
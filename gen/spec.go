@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Versions of the specs merged with -merge, oldest first. Empty when generating for a single spec.
+var specVersions []string
+
+// Spec is a webservices definition together with the SonarQube version it describes.
+type Spec struct {
+	Version string
+	Api     Api
+	// Source provides the response examples of this version, nil for plain spec files.
+	Source Source
+}
+
+// loadSpec loads a spec from a snapshot directory, a JSON file or a server url.
+// The version can be given as a label, e.g. 9.9=spec.json, and is required for JSON files.
+func loadSpec(location string) (*Spec, error) {
+	spec := &Spec{}
+	if i := strings.Index(location, "="); i > 0 && !strings.ContainsAny(location[:i], "/:") {
+		spec.Version, location = location[:i], location[i+1:]
+	}
+
+	var body []byte
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		server := newHTTPSource(location, auth, internal)
+		if spec.Version == "" {
+			version, err := server.Version()
+			if err != nil {
+				return nil, fmt.Errorf("could not determine version of %s: %w", location, err)
+			}
+			spec.Version = version
+		}
+		spec.Source = server
+	} else if stat, err := os.Stat(location); err != nil {
+		return nil, err
+	} else if stat.IsDir() {
+		snapshotSource, err := openSnapshot(location)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Version == "" {
+			spec.Version = snapshotSource.info.Version
+		}
+		spec.Source = snapshotSource
+	} else {
+		if spec.Version == "" {
+			return nil, fmt.Errorf("spec file %s needs a version label, e.g. 9.9=%s", location, location)
+		}
+		if body, err = ioutil.ReadFile(location); err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Source != nil {
+		var err error
+		if body, err = spec.Source.Webservices(); err != nil {
+			return nil, fmt.Errorf("failed to fetch api definitions from %s: %w", location, err)
+		}
+	}
+
+	if err := json.Unmarshal(body, &spec.Api); err != nil {
+		return nil, fmt.Errorf("could not decode api definitions from %s: %w", location, err)
+	}
+
+	return spec, nil
+}
+
+// compareVersions compares dotted version strings numerically, e.g. 9.9 < 10.4.0.87286.
+func compareVersions(a string, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// minVersion returns the lowest of the non-empty versions.
+func minVersion(a string, b string) string {
+	if a == "" || (b != "" && compareVersions(b, a) < 0) {
+		return b
+	}
+	return a
+}
+
+// mergeSpecs merges specs into a single Api containing every service, action and param of any of them.
+// The definitions are taken from the newest version that has them, and each one records the versions it is present in.
+// Since and DeprecatedSince are the earliest values any version reports.
+func mergeSpecs(specs []*Spec) Api {
+	sort.SliceStable(specs, func(i, j int) bool {
+		return compareVersions(specs[i].Version, specs[j].Version) < 0
+	})
+
+	services := map[string]*Service{}
+	for i := len(specs) - 1; i >= 0; i-- {
+		version := specs[i].Version
+		for _, service := range specs[i].Api.Services {
+			merged, ok := services[service.Path]
+			if !ok {
+				merged = &Service{Path: service.Path, Description: service.Description}
+				services[service.Path] = merged
+			}
+			merged.Versions = append([]string{version}, merged.Versions...)
+
+			for _, action := range service.Actions {
+				mergeAction(merged, action, version)
+			}
+		}
+	}
+
+	api := Api{}
+	for _, spec := range specs {
+		api.Versions = append(api.Versions, spec.Version)
+	}
+	paths := make([]string, 0, len(services))
+	for path := range services {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		api.Services = append(api.Services, *services[path])
+	}
+
+	return api
+}
+
+func mergeAction(service *Service, action Action, version string) {
+	var merged *Action
+	for i := range service.Actions {
+		if service.Actions[i].Key == action.Key {
+			merged = &service.Actions[i]
+		}
+	}
+	if merged == nil {
+		service.Actions = append(service.Actions, action)
+		merged = &service.Actions[len(service.Actions)-1]
+		merged.Params = nil
+	}
+
+	merged.Versions = append([]string{version}, merged.Versions...)
+	merged.Since = minVersion(merged.Since, action.Since)
+	merged.DeprecatedSince = minVersion(merged.DeprecatedSince, action.DeprecatedSince)
+
+	for _, param := range action.Params {
+		var mergedParam *Param
+		for i := range merged.Params {
+			if merged.Params[i].Key == param.Key {
+				mergedParam = &merged.Params[i]
+			}
+		}
+		if mergedParam == nil {
+			merged.Params = append(merged.Params, param)
+			mergedParam = &merged.Params[len(merged.Params)-1]
+		}
+
+		mergedParam.Versions = append([]string{version}, mergedParam.Versions...)
+		mergedParam.Since = minVersion(mergedParam.Since, param.Since)
+		mergedParam.DeprecatedSince = minVersion(mergedParam.DeprecatedSince, param.DeprecatedSince)
+	}
+}
+
+// compatibility describes which of the merged versions have something, e.g.
+// "Available in SonarQube 9.9.0.65466 to 10.2.0.77647, removed in 10.4.0.87286".
+// It is empty when generating for a single version, or when all merged versions have it.
+func compatibility(versions []string) string {
+	if len(specVersions) < 2 || len(versions) == 0 || len(versions) == len(specVersions) {
+		return ""
+	}
+
+	first, last := -1, -1
+	for i, version := range specVersions {
+		if version == versions[0] {
+			first = i
+		}
+		if version == versions[len(versions)-1] {
+			last = i
+		}
+	}
+
+	var text string
+	switch {
+	case len(versions) == 1:
+		text = fmt.Sprintf("Available in SonarQube %s", versions[0])
+	case last-first+1 == len(versions):
+		text = fmt.Sprintf("Available in SonarQube %s to %s", versions[0], versions[len(versions)-1])
+	default:
+		text = fmt.Sprintf("Available in SonarQube %s", strings.Join(versions, ", "))
+	}
+
+	if last < len(specVersions)-1 {
+		text += fmt.Sprintf(", removed in %s", specVersions[last+1])
+	}
+
+	return text
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"9.9", "10.4", -1},
+		{"10.4.0.87286", "10.4", 1},
+		{"10.4", "10.4.0", 0},
+		{"9.9.0.65466", "9.9.0.65466", 0},
+		{"10.10", "10.9", 1},
+	}
+
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMergeSpecs(t *testing.T) {
+	older := &Spec{Version: "9.9", Api: Api{Services: []Service{
+		{Path: "api/issues", Description: "old issues", Actions: []Action{
+			{Key: "search", Description: "old search", Since: "3.6", Params: []Param{{Key: "statuses"}, {Key: "hotspots"}}},
+			{Key: "bulk_change", Since: "3.7"},
+		}},
+		{Path: "api/properties", Actions: []Action{{Key: "index", DeprecatedSince: "6.3"}}},
+	}}}
+	newer := &Spec{Version: "10.4", Api: Api{Services: []Service{
+		{Path: "api/issues", Description: "issues", Actions: []Action{
+			{Key: "search", Description: "search", Since: "5.0", DeprecatedSince: "10.2", Params: []Param{{Key: "statuses"}, {Key: "impacts"}}},
+		}},
+		{Path: "api/features", Actions: []Action{{Key: "list", Since: "10.2"}}},
+	}}}
+
+	// The specs are sorted by version, whatever order they are given in
+	api := mergeSpecs([]*Spec{newer, older})
+
+	if want := []string{"9.9", "10.4"}; !reflect.DeepEqual(api.Versions, want) {
+		t.Errorf("versions are %v, want %v", api.Versions, want)
+	}
+
+	services := map[string]Service{}
+	var paths []string
+	for _, service := range api.Services {
+		services[service.Path] = service
+		paths = append(paths, service.Path)
+	}
+	if want := []string{"api/features", "api/issues", "api/properties"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("services are %v, want %v", paths, want)
+	}
+
+	if got := services["api/features"].Versions; !reflect.DeepEqual(got, []string{"10.4"}) {
+		t.Errorf("api/features is in %v, want only 10.4", got)
+	}
+	if got := services["api/properties"].Versions; !reflect.DeepEqual(got, []string{"9.9"}) {
+		t.Errorf("api/properties is in %v, want only 9.9", got)
+	}
+
+	issues := services["api/issues"]
+	if issues.Description != "issues" || !reflect.DeepEqual(issues.Versions, []string{"9.9", "10.4"}) {
+		t.Errorf("api/issues is %q in %v, want the newest description in both versions", issues.Description, issues.Versions)
+	}
+	if len(issues.Actions) != 2 {
+		t.Fatalf("api/issues has %d actions, want 2", len(issues.Actions))
+	}
+
+	search := issues.Actions[0]
+	if search.Key != "search" || search.Description != "search" || search.Since != "3.6" || search.DeprecatedSince != "10.2" {
+		t.Errorf("search is %q since %s deprecated since %s, want the newest description since 3.6 deprecated since 10.2", search.Description, search.Since, search.DeprecatedSince)
+	}
+	if !reflect.DeepEqual(search.Versions, []string{"9.9", "10.4"}) {
+		t.Errorf("search is in %v, want both versions", search.Versions)
+	}
+
+	params := map[string][]string{}
+	for _, param := range search.Params {
+		params[param.Key] = param.Versions
+	}
+	wantParams := map[string][]string{
+		"statuses": {"9.9", "10.4"},
+		"impacts":  {"10.4"},
+		"hotspots": {"9.9"},
+	}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("params are in %v, want %v", params, wantParams)
+	}
+
+	if bulk := issues.Actions[1]; bulk.Key != "bulk_change" || !reflect.DeepEqual(bulk.Versions, []string{"9.9"}) {
+		t.Errorf("%s is in %v, want bulk_change only in 9.9", bulk.Key, bulk.Versions)
+	}
+}
+
+func TestCompatibility(t *testing.T) {
+	specVersions = []string{"9.9", "10.2", "10.4"}
+	defer func() { specVersions = nil }()
+
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{[]string{"9.9", "10.2", "10.4"}, ""},
+		{[]string{"10.4"}, "Available in SonarQube 10.4"},
+		{[]string{"9.9", "10.2"}, "Available in SonarQube 9.9 to 10.2, removed in 10.4"},
+		{[]string{"9.9", "10.4"}, "Available in SonarQube 9.9, 10.4"},
+	}
+
+	for _, test := range tests {
+		if got := compatibility(test.versions); got != test.want {
+			t.Errorf("compatibility(%v) = %q, want %q", test.versions, got, test.want)
+		}
+	}
+}
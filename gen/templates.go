@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Templates in the -templates directory replace the generated code of the client, of the shared types package,
+// of each service or of each action.
+//
+// sonarqube.tpl and types.tpl are rendered with clientData and replace the whole file.
+// service.tpl is rendered once per service, with serviceTemplateData, and replaces the declaration of the service type.
+// action.tpl is rendered once per action, with actionTemplateData, and replaces the service method of the action.
+// Both are Go fragments, use {{qual "<import path>" "<name>"}} to refer to a qualified identifier, so the import is added.
+const (
+	serviceTemplateName = "service.tpl"
+	actionTemplateName  = "action.tpl"
+)
+
+var (
+	// nil when not overridden, the code is generated with jennifer then
+	serviceTemplate *template.Template
+	actionTemplate  *template.Template
+)
+
+// Delimits the qualified identifiers in rendered code templates
+const qualMarker = "\x00"
+
+var templateFuncs = template.FuncMap{
+	"camel":   strcase.ToCamel,
+	"comment": replaceTags,
+	"qual": func(path string, name string) string {
+		return qualMarker + path + " " + name + qualMarker
+	},
+}
+
+type serviceTemplateData struct {
+	Service  *Service
+	Getter   string
+	Endpoint string
+	// Import path of the type package of the service
+	Package string
+}
+
+type actionTemplateData struct {
+	Service      *Service
+	Action       *Action
+	Getter       string
+	Endpoint     string
+	Package      string
+	Method       string
+	FuncName     string
+	RequestType  string
+	ResponseType string
+	HasResponse  bool
+	HasPaging    bool
+	// Import path of the paging package
+	PagingPackage string
+}
+
+// loadTemplates loads the templates found in dir, the generated code stays as it is for the missing ones.
+func loadTemplates(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	var err error
+	if clientTemplate, err = loadTemplate(dir, clientTemplateName, clientTemplate); err != nil {
+		return err
+	}
+	if typesTemplate, err = loadTemplate(dir, typesTemplateName, typesTemplate); err != nil {
+		return err
+	}
+	if serviceTemplate, err = loadTemplate(dir, serviceTemplateName, nil); err != nil {
+		return err
+	}
+	if actionTemplate, err = loadTemplate(dir, actionTemplateName, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func loadTemplate(dir string, name string, fallback *template.Template) (*template.Template, error) {
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fallback, nil
+	}
+
+	tpl, err := template.New(name).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse template %s: %w", path, err)
+	}
+	return tpl, nil
+}
+
+// renderCode renders a code template into a statement, turning the qualified identifiers into Qual
+func renderCode(tpl *template.Template, data interface{}) (*Statement, error) {
+	buff := bytes.NewBuffer([]byte{})
+	if err := tpl.Execute(buff, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", tpl.Name(), err)
+	}
+
+	statement := Null()
+	for i, part := range strings.Split(buff.String(), qualMarker) {
+		if i%2 == 0 {
+			statement.Op(part)
+		} else {
+			path := part[:strings.LastIndex(part, " ")]
+			statement.Qual(path, part[len(path)+1:])
+		}
+	}
+
+	return statement.Line(), nil
+}
+
+func (s *Service) templateData() serviceTemplateData {
+	return serviceTemplateData{
+		Service:  s,
+		Getter:   s.Getter(),
+		Endpoint: s.endpoint(),
+		Package:  qualifier(s.endpoint()),
+	}
+}
+
+func (s *Service) actionTemplateData(action *Action) actionTemplateData {
+	return actionTemplateData{
+		Service:       s,
+		Action:        action,
+		Getter:        s.Getter(),
+		Endpoint:      s.endpoint(),
+		Package:       qualifier(s.endpoint()),
+		Method:        httpMethod(action.Post),
+		FuncName:      action.serviceFuncName(),
+		RequestType:   action.requestTypeName(),
+		ResponseType:  action.responseTypeName(),
+		HasResponse:   action.HasResponseExample,
+		HasPaging:     action.hasPaging() && !action.Post,
+		PagingPackage: qualifier("paging"),
+	}
+}
@@ -0,0 +1,70 @@
+package sonarqube
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type encodeRequest struct {
+	Statuses []string `form:"statuses,omitempty,comma" url:"statuses,omitempty,comma"`
+	Tags     []string `form:"tags,omitempty" url:"tags,omitempty"`
+	Key      string   `form:"key" url:"key"`
+	Name     string   `form:"name,omitempty" url:"name,omitempty"`
+	Since    Time     `form:"since,omitempty" url:"since,omitempty"`
+	Day      Date     `form:"day,omitempty" url:"day,omitempty"`
+}
+
+func TestEncodeForm(t *testing.T) {
+	since := time.Date(2024, 3, 1, 11, 39, 3, 0, time.FixedZone("", 3600))
+
+	tests := []struct {
+		name string
+		opt  encodeRequest
+		want url.Values
+	}{
+		{
+			name: "comma list",
+			opt:  encodeRequest{Key: "a", Statuses: []string{"OPEN,CONFIRMED"}},
+			want: url.Values{"key": {"a"}, "statuses": {"OPEN,CONFIRMED"}},
+		},
+		{
+			name: "repeated list",
+			opt:  encodeRequest{Key: "a", Tags: []string{"x", "y"}},
+			want: url.Values{"key": {"a"}, "tags": {"x", "y"}},
+		},
+		{
+			name: "empty values",
+			opt:  encodeRequest{},
+			want: url.Values{"key": {""}},
+		},
+		{
+			name: "times and dates",
+			opt:  encodeRequest{Key: "a", Since: Time{since}, Day: Date{since}},
+			want: url.Values{"key": {"a"}, "since": {"2024-03-01T11:39:03+0100"}, "day": {"2024-03-01"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := encodeForm(test.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, test.want) {
+				t.Errorf("form values are %v, want %v", values, test.want)
+			}
+
+			// GET requests send the same values in the query
+			u, err := addOptions("http://sonar/api/issues/search", test.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, _ := url.Parse(u)
+			if query := parsed.Query(); !reflect.DeepEqual(query, test.want) {
+				t.Errorf("query values are %v, want %v", query, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimeUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Time
+	}{
+		{`"2017-03-01T11:39:03+0100"`, time.Date(2017, 3, 1, 11, 39, 3, 0, time.FixedZone("", 3600))},
+		{`"2017-03-01T11:39:03+01:00"`, time.Date(2017, 3, 1, 11, 39, 3, 0, time.FixedZone("", 3600))},
+		{`"2017-03-01"`, time.Date(2017, 3, 1, 0, 0, 0, 0, time.Local)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+	}
+
+	for _, test := range tests {
+		var got Time
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Errorf("unmarshalling %s failed: %v", test.json, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("unmarshalling %s gave %s, want %s", test.json, got.Time, test.want)
+		}
+	}
+
+	var got Time
+	if err := json.Unmarshal([]byte(`"yesterday"`), &got); err == nil {
+		t.Errorf("unmarshalling an invalid time gave %s", got)
+	}
+}
+
+func TestDateUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`"2017-03-01"`, "2017-03-01"},
+		// The date of a timestamp is the one in its own offset, not in the local time zone
+		{`"2017-03-01T00:30:00+0100"`, "2017-03-01"},
+		{`"2017-03-01T23:30:00-0500"`, "2017-03-01"},
+	}
+
+	for _, test := range tests {
+		var got Date
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Errorf("unmarshalling %s failed: %v", test.json, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("unmarshalling %s gave %s, want %s", test.json, got, test.want)
+		}
+	}
+
+	var got Date
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil || !got.IsZero() {
+		t.Errorf("unmarshalling null gave %s: %v", got, err)
+	}
+}
+
+func TestTimeMarshal(t *testing.T) {
+	moment := time.Date(2017, 3, 1, 11, 39, 3, 0, time.FixedZone("", 3600))
+	value := struct {
+		Time  Time  `json:"time"`
+		Date  Date  `json:"date"`
+		Empty Time  `json:"empty"`
+		None  *Date `json:"none,omitempty"`
+	}{Time: Time{moment}, Date: Date{moment}}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"time":"2017-03-01T11:39:03+0100","date":"2017-03-01","empty":""}`; string(body) != want {
+		t.Errorf("marshalled %s, want %s", body, want)
+	}
+
+	values := url.Values{}
+	Time{moment}.EncodeValues("from", &values)
+	Date{moment}.EncodeValues("day", &values)
+	if want := "day=2017-03-01&from=2017-03-01T11%3A39%3A03%2B0100"; values.Encode() != want {
+		t.Errorf("encoded %s, want %s", values.Encode(), want)
+	}
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(v *ValidationError)
+		want  []Violation
+	}{
+		{
+			name:  "required",
+			check: func(v *ValidationError) { v.Required("key", false); v.Required("name", true) },
+			want:  []Violation{{"key", "is required"}},
+		},
+		{
+			name: "one of",
+			check: func(v *ValidationError) {
+				v.OneOf("status", "", "OPEN", "CLOSED")
+				v.OneOf("status", "OPEN", "OPEN", "CLOSED")
+				v.OneOf("type", "BUG", "CODE_SMELL")
+			},
+			want: []Violation{{"type", `must be one of CODE_SMELL, got "BUG"`}},
+		},
+		{
+			name:  "max values",
+			check: func(v *ValidationError) { v.MaxValues("keys", 3, 2); v.MaxValues("tags", 2, 2) },
+			want:  []Violation{{"keys", "must have at most 2 values, got 3"}},
+		},
+		{
+			name: "length in characters",
+			check: func(v *ValidationError) {
+				v.MinLength("name", "", 2)
+				v.MinLength("name", "é", 2)
+				v.MinLength("login", "éé", 2)
+				v.MaxLength("description", "日本語", 3)
+				v.MaxLength("description", "日本語!", 3)
+			},
+			want: []Violation{{"name", "must be at least 2 characters long"}, {"description", "must be at most 3 characters long"}},
+		},
+		{
+			name:  "bounds",
+			check: func(v *ValidationError) { v.Min("ps", 0, 1); v.Max("ps", 501, 500); v.Max("p", 1, 500) },
+			want:  []Violation{{"ps", "must be at least 1, got 0"}, {"ps", "must be at most 500, got 501"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := NewValidation("SearchRequest")
+			test.check(v)
+			if !reflect.DeepEqual(v.Violations, test.want) {
+				t.Errorf("violations are %q, want %q", v.Violations, test.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorErr(t *testing.T) {
+	v := NewValidation("SearchRequest")
+	if err := v.Err(); err != nil {
+		t.Errorf("no violations gave %v", err)
+	}
+
+	v.Required("key", false)
+	v.Max("ps", 501, 500)
+	err := v.Err()
+
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Request != "SearchRequest" {
+		t.Fatalf("error %v is not the validation error", err)
+	}
+	if want := "invalid SearchRequest: key is required; ps must be at most 500, got 501"; err.Error() != want {
+		t.Errorf("error is %q, want %q", err.Error(), want)
+	}
+}
@@ -1,4 +1,4 @@
-package sonarqube
+package {{.Package}}
 
 import (
 	"context"
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/go-playground/form/v4"
 	"github.com/google/go-querystring/query"
+	"{{.TypesPackage}}"
 	"io"
 	"net/http"
 	"net/url"
@@ -18,7 +19,10 @@ const (
 	privateToken
 	Anonymous
 )
-
+{{if .Versions}}
+// Client covers SonarQube {{range $i, $version := .Versions}}{{if $i}}, {{end}}{{$version}}{{end}}.
+{{- else}}
+{{end}}
 type Client struct {
 	client   *http.Client
 	host      string
@@ -28,10 +32,24 @@ type Client struct {
 	authType int
 
 {{- range .Services}}
-	{{.Getter}} *{{.Getter}}
+	{{.Getter}} *{{.Getter}}{{with .Compatibility}} // {{.}}{{end}}
 {{- end }}
 }
 
+// ValidationError is returned for requests that do not meet the constraints of the web service spec
+type ValidationError = types.ValidationError
+
+// Time and Date are the timestamps and dates of requests and responses, see types.TimeFormat and types.DateFormat
+type (
+	Time = types.Time
+	Date = types.Date
+)
+
+// Ptr returns a pointer to v, to fill the optional fields of requests
+func Ptr[T any](v T) *T {
+	return &v
+}
+
 type service struct {
 	client *Client
 	path   string
@@ -144,10 +162,9 @@ func (c *Client) Call(ctx context.Context, method string, u string, v interface{
 		}
 	} else {
 		values := make(url.Values)
-		encoder := form.NewEncoder()
 
 		for _, o := range opt {
-			vs, err := encoder.Encode(o)
+			vs, err := encodeForm(o)
 			if err != nil {
 				return nil, fmt.Errorf("could not encode form values: %v", err)
 			}
@@ -220,3 +237,44 @@ func addOptions(s string, opt interface{}) (string, error) {
 
 	return origURL.String(), nil
 }
+
+// encodeForm encodes the POST values of a request. List fields tagged with the comma option are sent
+// as a single comma-separated value, and times and dates in their SonarQube format, the same as
+// go-querystring does for GET requests.
+func encodeForm(opt interface{}) (url.Values, error) {
+	comma := map[string]bool{}
+
+	encoder := form.NewEncoder()
+	encoder.RegisterCustomTypeFunc(func(value interface{}) ([]string, error) {
+		return []string{value.(fmt.Stringer).String()}, nil
+	}, types.Time{}, types.Date{})
+	encoder.RegisterTagNameFunc(func(field reflect.StructField) string {
+		options := strings.Split(field.Tag.Get("form"), ",")
+		name, omitEmpty := options[0], false
+		for _, option := range options[1:] {
+			switch option {
+			case "comma":
+				comma[name] = true
+			case "omitempty":
+				omitEmpty = true
+			}
+		}
+
+		if omitEmpty {
+			return name + ",omitempty"
+		}
+		return name
+	})
+
+	values, err := encoder.Encode(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range comma {
+		if vs, ok := values[name]; ok {
+			values[name] = []string{strings.Join(vs, ",")}
+		}
+	}
+	return values, nil
+}
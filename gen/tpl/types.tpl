@@ -0,0 +1,208 @@
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// AUTOMATICALLY GENERATED, DO NOT EDIT BY HAND!
+
+// ValidationError is returned by the Validate method of a request, and by the service methods
+// before sending a request that does not meet the constraints of the web service spec.
+type ValidationError struct {
+	Request    string
+	Violations []Violation
+}
+
+// Violation is a param of the request that does not meet a constraint.
+type Violation struct {
+	Param   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	violations := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		violations[i] = fmt.Sprintf("%s %s", violation.Param, violation.Message)
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Request, strings.Join(violations, "; "))
+}
+
+// NewValidation starts the validation of a request, the checks record their violations.
+func NewValidation(request string) *ValidationError {
+	return &ValidationError{Request: request}
+}
+
+// Err returns the validation error when there are violations, nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Add(param string, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, Violation{Param: param, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) Required(param string, present bool) {
+	if !present {
+		e.Add(param, "is required")
+	}
+}
+
+// OneOf checks that a non-empty value is one of the allowed values.
+func (e *ValidationError) OneOf(param string, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	e.Add(param, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// MaxValues checks the number of values of a list.
+func (e *ValidationError) MaxValues(param string, count int, max int) {
+	if count > max {
+		e.Add(param, "must have at most %d values, got %d", max, count)
+	}
+}
+
+// MinLength checks the length of a non-empty value, in characters.
+func (e *ValidationError) MinLength(param string, value string, min int) {
+	if value != "" && utf8.RuneCountInString(value) < min {
+		e.Add(param, "must be at least %d characters long", min)
+	}
+}
+
+func (e *ValidationError) MaxLength(param string, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		e.Add(param, "must be at most %d characters long", max)
+	}
+}
+
+func (e *ValidationError) Min(param string, value int, min int) {
+	if value < min {
+		e.Add(param, "must be at least %d, got %d", min, value)
+	}
+}
+
+func (e *ValidationError) Max(param string, value int, max int) {
+	if value > max {
+		e.Add(param, "must be at most %d, got %d", max, value)
+	}
+}
+
+// The formats of SonarQube timestamps, e.g. 2017-03-01T11:39:03+0100, and dates, e.g. 2017-03-01
+const (
+	TimeFormat = "2006-01-02T15:04:05-0700"
+	DateFormat = "2006-01-02"
+)
+
+// Time is a SonarQube timestamp. It is sent in TimeFormat, and read from either a timestamp or a date.
+type Time struct {
+	time.Time
+}
+
+// ParseTime parses a timestamp, with or without a colon in its offset, or a date in the local time zone.
+func ParseTime(value string) (Time, error) {
+	for _, layout := range []string{TimeFormat, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return Time{t}, nil
+		}
+	}
+	t, err := time.ParseInLocation(DateFormat, value, time.Local)
+	if err != nil {
+		return Time{}, fmt.Errorf("invalid time %q, expected the format %s or %s", value, TimeFormat, DateFormat)
+	}
+	return Time{t}, nil
+}
+
+func (t Time) String() string {
+	return t.Format(TimeFormat)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON reads a timestamp or a date, null and empty strings give the zero time.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	value, err := unquote(data)
+	if err != nil || value == "" {
+		*t = Time{}
+		return err
+	}
+	*t, err = ParseTime(value)
+	return err
+}
+
+// EncodeValues encodes the param of GET requests, see github.com/google/go-querystring/query.Encoder.
+func (t Time) EncodeValues(key string, values *url.Values) error {
+	values.Set(key, t.String())
+	return nil
+}
+
+// Date is a SonarQube date. It is sent in DateFormat, and read from either a date or a timestamp.
+type Date struct {
+	time.Time
+}
+
+// ParseDate parses a date, or the date of a timestamp in its own offset.
+func ParseDate(value string) (Date, error) {
+	if t, err := time.ParseInLocation(DateFormat, value, time.Local); err == nil {
+		return Date{t}, nil
+	}
+	t, err := ParseTime(value)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, expected the format %s or %s", value, DateFormat, TimeFormat)
+	}
+	return Date{time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(DateFormat)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a date or a timestamp, null and empty strings give the zero date.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	value, err := unquote(data)
+	if err != nil || value == "" {
+		*d = Date{}
+		return err
+	}
+	*d, err = ParseDate(value)
+	return err
+}
+
+// EncodeValues encodes the param of GET requests, see github.com/google/go-querystring/query.Encoder.
+func (d Date) EncodeValues(key string, values *url.Values) error {
+	values.Set(key, d.String())
+	return nil
+}
+
+func unquote(data []byte) (string, error) {
+	if string(data) == "null" {
+		return "", nil
+	}
+	var value string
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed file",
+			a:    "a\n",
+			b:    "",
+			want: "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "distant changes",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", test.a, test.b); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 5000; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append([]string{"package main"}, a...)
+	b = append([]string{"package main"}, b...)
+
+	ops := diffLines(a, b)
+
+	var kinds strings.Builder
+	for _, op := range ops {
+		if kinds.Len() == 0 || kinds.String()[kinds.Len()-1] != op.kind {
+			kinds.WriteByte(op.kind)
+		}
+	}
+	if len(ops) != 1+2*5000 || kinds.String() != " -+" {
+		t.Errorf("got %d ops in the runs %q, want the common line, then all of a removed and all of b added", len(ops), kinds.String())
+	}
+}
+
+func TestCheckWriterHeaders(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "issues"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	changed, removed := filepath.Join(root, "issues", "issues_gen.go"), filepath.Join(root, "hotspots_gen.go")
+	for _, fileName := range []string{changed, removed} {
+		if err := os.WriteFile(fileName, []byte("package issues\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := newCheckWriter(root)
+	w.WriteFile(changed, []byte("package issues\n\ntype A struct{}\n"))
+	w.WriteFile(filepath.Join(root, "added_gen.go"), []byte("package sonarqube\n"))
+	w.Remove(removed)
+
+	var out strings.Builder
+	if n := w.writeDiffs(&out); n != 3 {
+		t.Errorf("%d files differ, want 3", n)
+	}
+	for _, header := range []string{
+		"--- /dev/null\n+++ b/added_gen.go\n",
+		"--- a/hotspots_gen.go\n+++ /dev/null\n",
+		"--- a/issues/issues_gen.go\n+++ b/issues/issues_gen.go\n",
+	} {
+		if !strings.Contains(out.String(), header) {
+			t.Errorf("no header %q in\n%s", header, out.String())
+		}
+	}
+}
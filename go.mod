@@ -5,4 +5,5 @@ go 1.20
 require (
 	github.com/dave/jennifer v1.4.1
 	github.com/iancoleman/strcase v0.2.0
+	gopkg.in/yaml.v3 v3.0.1
 )